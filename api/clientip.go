@@ -0,0 +1,139 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// clientIPResolver finds the client address of a request, forwarding headers are only
+// believed when they were added by one of the trusted proxies
+type clientIPResolver struct {
+	trustedProxies []*net.IPNet
+}
+
+func newClientIPResolver(trustedProxies []string) (*clientIPResolver, error) {
+	networks, err := parseNetworks(trustedProxies)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted proxy: %w", err)
+	}
+
+	return &clientIPResolver{trustedProxies: networks}, nil
+}
+
+func (r *clientIPResolver) resolve(req *http.Request) string {
+	remoteIP := parseHostIP(req.RemoteAddr)
+	if remoteIP == nil {
+		return ""
+	}
+
+	if !r.trusted(remoteIP) {
+		return remoteIP.String()
+	}
+
+	// Walking the chain from the nearest hop, the first address which is not
+	// a trusted proxy is the client, anything before it could be spoofed
+	chain := forwardedChain(req)
+	clientIP := remoteIP
+	for i := len(chain) - 1; i >= 0; i-- {
+		hopIP := parseHostIP(chain[i])
+		if hopIP == nil {
+			break
+		}
+
+		clientIP = hopIP
+		if !r.trusted(hopIP) {
+			break
+		}
+	}
+
+	return clientIP.String()
+}
+
+func (r *clientIPResolver) trusted(ip net.IP) bool {
+	return containsIP(r.trustedProxies, ip)
+}
+
+// forwardedChain returns the addresses from the Forwarded header, or X-Forwarded-For
+// if there is none, ordered from the client to the last proxy
+func forwardedChain(req *http.Request) []string {
+	var chain []string
+
+	if forwarded := req.Header.Values("Forwarded"); len(forwarded) > 0 {
+		for _, element := range strings.Split(strings.Join(forwarded, ","), ",") {
+			for _, pair := range strings.Split(element, ";") {
+				key, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+				if found && strings.EqualFold(key, "for") {
+					chain = append(chain, strings.Trim(value, `"`))
+				}
+			}
+		}
+
+		return chain
+	}
+
+	for _, forwardedFor := range req.Header.Values("X-Forwarded-For") {
+		for _, address := range strings.Split(forwardedFor, ",") {
+			chain = append(chain, strings.TrimSpace(address))
+		}
+	}
+
+	return chain
+}
+
+// parseHostIP parses "1.2.3.4", "1.2.3.4:80", "[2001:db8::1]:80" and "2001:db8::1",
+// obfuscated identifiers like "unknown" or "_hidden" return nil
+func parseHostIP(address string) net.IP {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+
+	return net.ParseIP(strings.Trim(address, "[]"))
+}
+
+// parseNetworks parses a list of CIDRs, plain IPs are treated as single host networks
+func parseNetworks(values []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("%s is not an IP or CIDR", value)
+			}
+
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, err
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"fmt"
+	"net"
+	"sync"
+)
+
+var (
+	ErrIPBanned           = fmt.Errorf("ip is banned")
+	ErrTooManyConnections = fmt.Errorf("too many connections from ip")
+)
+
+// ipFilter enforces the ban list and the number of open connections per client IP,
+// both can be changed while the server is running
+type ipFilter struct {
+	mu                  sync.Mutex
+	bans                []*net.IPNet
+	maxConnectionsPerIP int
+	connections         map[string]int
+}
+
+func newIPFilter(bans []string, maxConnectionsPerIP int) (*ipFilter, error) {
+	f := &ipFilter{connections: map[string]int{}}
+
+	if err := f.update(bans, maxConnectionsPerIP); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// update replaces the ban list and connection limit, a limit of 0 means unlimited
+func (f *ipFilter) update(bans []string, maxConnectionsPerIP int) error {
+	networks, err := parseNetworks(bans)
+	if err != nil {
+		return fmt.Errorf("invalid ip ban: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.bans = networks
+	f.maxConnectionsPerIP = maxConnectionsPerIP
+
+	return nil
+}
+
+func (f *ipFilter) banned(ip string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.bannedLocked(ip)
+}
+
+func (f *ipFilter) bannedLocked(ip string) bool {
+	parsedIP := net.ParseIP(ip)
+	return parsedIP != nil && containsIP(f.bans, parsedIP)
+}
+
+// acquire reserves a connection slot for the ip, it must be released once the connection is closed
+func (f *ipFilter) acquire(ip string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.bannedLocked(ip) {
+		return ErrIPBanned
+	}
+
+	if f.maxConnectionsPerIP > 0 && f.connections[ip] >= f.maxConnectionsPerIP {
+		return ErrTooManyConnections
+	}
+
+	f.connections[ip]++
+
+	return nil
+}
+
+func (f *ipFilter) release(ip string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.connections[ip]--
+	if f.connections[ip] <= 0 {
+		delete(f.connections, ip)
+	}
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// originPolicy decides which browser origins may open websockets and read the HTTP endpoints.
+// Allowed origins are either exact ("https://game.example.com"), wildcard subdomains
+// ("https://*.example.com", any scheme if left out: "*.example.com") or "*" for any origin.
+type originPolicy struct {
+	allowedOrigins []originPattern
+	allowAll       bool
+}
+
+type originPattern struct {
+	scheme string
+	host   string
+	// wildcard matches any subdomain of host, but not host itself
+	wildcard bool
+}
+
+func newOriginPolicy(allowedOrigins []string) (*originPolicy, error) {
+	policy := &originPolicy{}
+
+	for _, origin := range allowedOrigins {
+		origin = strings.ToLower(strings.TrimSpace(origin))
+		if origin == "" {
+			continue
+		}
+
+		if origin == "*" {
+			policy.allowAll = true
+			continue
+		}
+
+		pattern := originPattern{}
+		if scheme, host, found := strings.Cut(origin, "://"); found {
+			pattern.scheme = scheme
+			origin = host
+		}
+
+		if strings.HasPrefix(origin, "*.") {
+			pattern.wildcard = true
+			origin = strings.TrimPrefix(origin, "*.")
+		}
+
+		if origin == "" || strings.ContainsAny(origin, "/*") {
+			return nil, fmt.Errorf("invalid allowed origin: %s", origin)
+		}
+
+		pattern.host = origin
+		policy.allowedOrigins = append(policy.allowedOrigins, pattern)
+	}
+
+	return policy, nil
+}
+
+// checkWebsocketOrigin is used as the websocket upgrader's CheckOrigin, requests without
+// an Origin header come from non-browser clients and same-origin requests are always allowed
+func (p *originPolicy) checkWebsocketOrigin(req *http.Request) bool {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	originURL, err := url.Parse(origin)
+	if err != nil {
+		logrus.WithField("origin", origin).Warnf("refusing websocket upgrade: malformed origin: %s", err.Error())
+		return false
+	}
+
+	if strings.EqualFold(originURL.Host, req.Host) {
+		return true
+	}
+
+	if p.allowed(originURL) {
+		return true
+	}
+
+	logrus.WithField("origin", origin).Warn("refusing websocket upgrade: cross-site origin is not in the allowed origins")
+
+	return false
+}
+
+func (p *originPolicy) allowed(originURL *url.URL) bool {
+	if p.allowAll {
+		return true
+	}
+
+	scheme := strings.ToLower(originURL.Scheme)
+	host := strings.ToLower(originURL.Host)
+
+	for _, pattern := range p.allowedOrigins {
+		if pattern.scheme != "" && pattern.scheme != scheme {
+			continue
+		}
+
+		if pattern.wildcard && strings.HasSuffix(host, "."+pattern.host) {
+			return true
+		}
+
+		if !pattern.wildcard && host == pattern.host {
+			return true
+		}
+	}
+
+	return false
+}
+
+// cors adds CORS headers for allowed origins and answers preflight requests
+func (p *originPolicy) cors(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Add("Vary", "Origin")
+
+		origin := req.Header.Get("Origin")
+		allowed := false
+		if origin != "" {
+			if originURL, err := url.Parse(origin); err == nil {
+				allowed = p.allowed(originURL)
+			}
+		}
+
+		if allowed {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		}
+
+		if req.Method == http.MethodOptions {
+			if !allowed {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next(w, req)
+	}
+}
@@ -2,41 +2,112 @@ package api
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"expvar"
 	"fmt"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/olahol/melody"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/ScruffyPants/talk-to-zombies/communication"
+	"github.com/ScruffyPants/talk-to-zombies/logging"
+	"github.com/ScruffyPants/talk-to-zombies/protocol"
+	"github.com/ScruffyPants/talk-to-zombies/tracing"
 )
 
 type Router struct {
-	httpServer       *http.Server
+	httpServer *http.Server
+	// adminServer serves the metrics away from the public listener, nil if disabled
+	adminServer      *http.Server
 	websocketHandler *melody.Melody
 
 	communicationService communication.Service
+	statusProvider       StatusProvider
+
+	clientIPResolver *clientIPResolver
+	ipFilter         *ipFilter
+	outbound         communication.QueueSettings
+	drainPeriod      time.Duration
+
+	startedAt time.Time
+	listening atomic.Bool
+	draining  atomic.Bool
 }
 
 type RouterSettings struct {
-	Address string
+	Address       string
+	WebsocketPath string
 
 	PingInterval time.Duration
 	PongWait     time.Duration
 	WriteWait    time.Duration
+
+	// AdminAddress serves /debug/vars on a listener of its own, kept off the public address, disabled if empty
+	AdminAddress string
+
+	// DrainPeriod is how long /readyz reports draining before the listener closes on shutdown,
+	// giving load balancers time to take the node out of rotation
+	DrainPeriod time.Duration
+
+	TLS TLSSettings
+
+	// AllowedOrigins lists cross-site origins allowed to open websockets and call the HTTP endpoints
+	AllowedOrigins []string
+
+	// TrustedProxies lists the IPs and CIDRs whose forwarding headers are believed
+	TrustedProxies      []string
+	IPBans              []string
+	MaxConnectionsPerIP int
+
+	// Outbound is the queue every websocket connection's messages wait in
+	Outbound communication.QueueSettings
 }
 
-func NewRouter(settings RouterSettings, communicationService communication.Service) *Router {
+func NewRouter(settings RouterSettings, communicationService communication.Service, statusProvider StatusProvider) (*Router, error) {
+	tlsConfig, err := newTLSConfig(settings.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	originPolicy, err := newOriginPolicy(settings.AllowedOrigins)
+	if err != nil {
+		return nil, err
+	}
+
+	clientIPResolver, err := newClientIPResolver(settings.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	ipFilter, err := newIPFilter(settings.IPBans, settings.MaxConnectionsPerIP)
+	if err != nil {
+		return nil, err
+	}
+
 	mux := &http.ServeMux{}
 
 	r := &Router{
 		communicationService: communicationService,
+		statusProvider:       statusProvider,
+		clientIPResolver:     clientIPResolver,
+		ipFilter:             ipFilter,
+		outbound:             settings.Outbound,
+		drainPeriod:          settings.DrainPeriod,
+		startedAt:            time.Now(),
 		httpServer: &http.Server{
-			Addr:    settings.Address,
-			Handler: mux,
+			Addr:      settings.Address,
+			Handler:   mux,
+			TLSConfig: tlsConfig,
 		},
 	}
 
@@ -44,59 +115,183 @@ func NewRouter(settings RouterSettings, communicationService communication.Servi
 	r.websocketHandler.Config.PingPeriod = settings.PingInterval
 	r.websocketHandler.Config.PongWait = settings.PongWait
 	r.websocketHandler.Config.WriteWait = settings.WriteWait
+	r.websocketHandler.Upgrader.CheckOrigin = originPolicy.checkWebsocketOrigin
+	r.websocketHandler.Upgrader.Subprotocols = []string{protocol.Subprotocol}
+
+	mux.HandleFunc("/healthz", originPolicy.cors(r.handleHealthz))
+	mux.HandleFunc("/readyz", originPolicy.cors(r.handleReadyz))
+	mux.HandleFunc("/version", originPolicy.cors(r.handleVersion))
+
+	mux.HandleFunc(settings.WebsocketPath, r.handleWebsocket)
+
+	if settings.AdminAddress != "" {
+		adminMux := &http.ServeMux{}
+		adminMux.Handle("/debug/vars", expvar.Handler())
 
-	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
-		if err := r.websocketHandler.HandleRequest(w, req); err != nil {
-			logrus.Errorf("error handling request: %s", err.Error())
+		r.adminServer = &http.Server{
+			Addr:    settings.AdminAddress,
+			Handler: adminMux,
 		}
-	})
+	}
 
 	r.websocketHandler.HandleConnect(r.OnConnect)
 	r.websocketHandler.HandleMessage(r.HandleMessage)
+	r.websocketHandler.HandleMessageBinary(r.HandleMessageBinary)
 	r.websocketHandler.HandleDisconnect(r.HandleDisconnect)
+	r.websocketHandler.HandleSentMessage(r.HandleSentMessage)
+	r.websocketHandler.HandleSentMessageBinary(r.HandleSentMessage)
 
-	return r
+	return r, nil
 }
 
 func (r *Router) Start() {
-	go func() {
-		if err := r.httpServer.ListenAndServe(); err != nil {
+	// Listening before returning makes sure the server accepts connections once Start is done
+	listener, err := net.Listen("tcp", r.httpServer.Addr)
+	if err != nil {
+		panic(err)
+	}
+
+	if r.httpServer.TLSConfig != nil {
+		listener = tls.NewListener(listener, r.httpServer.TLSConfig)
+	}
+
+	if r.adminServer != nil {
+		adminListener, err := net.Listen("tcp", r.adminServer.Addr)
+		if err != nil {
 			panic(err)
 		}
-	}()
+
+		go serve(r.adminServer, adminListener)
+	}
+
+	r.listening.Store(true)
+
+	go serve(r.httpServer, listener)
+}
+
+func serve(server *http.Server, listener net.Listener) {
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		panic(err)
+	}
+}
+
+// Shutdown marks the router as not ready and, after the drain period, stops accepting new
+// connections, already open websocket connections are left untouched
+func (r *Router) Shutdown(ctx context.Context) error {
+	r.draining.Store(true)
+
+	if r.drainPeriod > 0 {
+		logrus.Infof("draining for %s before closing the listener", r.drainPeriod)
+
+		timer := time.NewTimer(r.drainPeriod)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+	}
+
+	err := r.httpServer.Shutdown(ctx)
+
+	if r.adminServer != nil {
+		if adminErr := r.adminServer.Shutdown(ctx); err == nil {
+			err = adminErr
+		}
+	}
+
+	return err
+}
+
+// UpdateIPFilter replaces the ban list and per IP connection limit, open connections
+// from newly banned IPs are closed
+func (r *Router) UpdateIPFilter(bans []string, maxConnectionsPerIP int) error {
+	if err := r.ipFilter.update(bans, maxConnectionsPerIP); err != nil {
+		return err
+	}
+
+	sessions, err := r.websocketHandler.Sessions()
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		connectionIP, ok := session.Get(logging.FieldConnectionIP)
+		if !ok || !r.ipFilter.banned(connectionIP.(string)) {
+			continue
+		}
+
+		logrus.WithContext(sessionContext(session)).Info("closing connection from banned ip")
+
+		if err = session.CloseWithMsg(melody.FormatCloseMessage(websocket.ClosePolicyViolation, "banned")); err != nil {
+			logrus.WithContext(sessionContext(session)).Errorf("error closing with message: %s", err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (r *Router) handleWebsocket(w http.ResponseWriter, req *http.Request) {
+	connectionIP := r.clientIPResolver.resolve(req)
+
+	if err := r.ipFilter.acquire(connectionIP); err != nil {
+		logrus.WithField(logging.FieldConnectionIP, connectionIP).Warnf("refusing websocket connection: %s", err.Error())
+
+		status := http.StatusForbidden
+		if errors.Is(err, ErrTooManyConnections) {
+			status = http.StatusTooManyRequests
+		}
+		http.Error(w, err.Error(), status)
+
+		return
+	}
+	// HandleRequestWithKeys only returns once the websocket connection is closed
+	defer r.ipFilter.release(connectionIP)
+
+	keys := map[string]interface{}{logging.FieldConnectionIP: connectionIP}
+	if err := r.websocketHandler.HandleRequestWithKeys(w, req, keys); err != nil {
+		logrus.WithField(logging.FieldConnectionIP, connectionIP).Errorf("error handling request: %s", err.Error())
+	}
 }
 
 func (r *Router) OnConnect(session *melody.Session) {
-	connectionIP := getUserIP(session.Request)
+	connectionIP, _ := session.Get(logging.FieldConnectionIP)
+	ctx := logging.WithFields(context.Background(), logrus.Fields{
+		logging.FieldConnectionIP: connectionIP,
+		logging.FieldAPI:          "websocket",
+	})
+
+	connection := newMelodySessionConnection(session, r.websocketHandler.Config.MessageBufferSize, negotiatedBinary(session.Request))
+	queuedConnection := communication.NewQueuedConnection(connection, r.outbound)
+	session.Set(keyConnection, connection)
+	session.Set(keyQueuedConnection, queuedConnection)
+	session.Set(keyStopConnection, func() {
+		queuedConnection.Stop()
+		connection.stop()
+	})
 
-	connectionID, err := r.communicationService.NewConnection(&melodySessionConnection{session})
+	connectionID, err := r.communicationService.NewConnection(queuedConnection)
 	if err != nil {
-		logrus.Infof("error creating new connection: %s", err.Error())
+		logrus.WithContext(ctx).Infof("error creating new connection: %s", err.Error())
 
 		if err = session.CloseWithMsg([]byte(fmt.Sprintf("error creating new connection: %s", err.Error()))); err != nil {
-			logrus.Infof("error closing with message: %s", err.Error())
+			logrus.WithContext(ctx).Infof("error closing with message: %s", err.Error())
 		}
 	}
 
-	session.Set("connection_id", connectionID)
-	session.Set("connection_ip", connectionIP)
-	session.Set("api", "websocket")
+	session.Set(logging.FieldConnectionID, connectionID)
+	session.Set(logging.FieldAPI, "websocket")
 	session.Set("connection_time", time.Now())
+
+	logrus.WithContext(sessionContext(session)).Debug("websocket connected")
 }
 
 func (r *Router) HandleMessage(session *melody.Session, bytes []byte) {
-	ctx := context.Background()
-	for k, v := range session.Keys {
-		ctx = context.WithValue(ctx, k, v)
-	}
-
 	messageString := string(bytes)
 	messageSplit := strings.Split(messageString, " ")
 
 	if len(messageSplit) == 0 {
-		if err := session.Write([]byte(fmt.Sprintf("message cannot be empty"))); err != nil {
-			logrus.WithContext(ctx).Errorf("error writting message to ws connection: %s", err.Error())
-		}
+		r.reply(session, "message cannot be empty")
 		return
 	}
 
@@ -108,7 +303,46 @@ func (r *Router) HandleMessage(session *melody.Session, bytes []byte) {
 		message.Arguments = messageSplit[1:]
 	}
 
-	connectionID, ok := session.Get("connection_id")
+	r.handleMessage(session, message)
+}
+
+// HandleMessageBinary dispatches on the type of a binary protocol frame, frames without
+// a command of their own carry a text command
+func (r *Router) HandleMessageBinary(session *melody.Session, bytes []byte) {
+	frame, err := protocol.Unmarshal(bytes)
+	if err != nil {
+		r.reply(session, fmt.Sprintf("invalid frame: %s", err.Error()))
+		return
+	}
+
+	var message communication.Message
+
+	switch f := frame.(type) {
+	case protocol.Start:
+		message = communication.Message{Type: "START", Arguments: append([]string{f.Username}, f.Rules...)}
+	case protocol.Join:
+		message = communication.Message{Type: "JOIN", Arguments: []string{f.GameID, f.Username}}
+	case protocol.Shoot:
+		message = communication.Message{Type: "SHOOT", Arguments: []string{strconv.Itoa(f.X), strconv.Itoa(f.Y)}}
+	case protocol.Command:
+		r.HandleMessage(session, []byte(f.Text))
+		return
+	default:
+		r.reply(session, fmt.Sprintf("unexpected frame type 0x%02x", byte(frame.Type())))
+		return
+	}
+
+	r.handleMessage(session, message)
+}
+
+func (r *Router) handleMessage(session *melody.Session, message communication.Message) {
+	ctx, span := tracing.Tracer().Start(sessionContext(session), "websocket.HandleMessage",
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(tracing.AttributeCommand.String(message.Type)),
+	)
+	defer span.End()
+
+	connectionID, ok := session.Get(logging.FieldConnectionID)
 	if !ok {
 		if err := session.CloseWithMsg([]byte("connection ID not found, disconnecting")); err != nil {
 			logrus.WithContext(ctx).Errorf("error closing with message: %s", err.Error())
@@ -117,48 +351,159 @@ func (r *Router) HandleMessage(session *melody.Session, bytes []byte) {
 	}
 
 	if err := r.communicationService.HandleMessage(ctx, connectionID.(string), message); err != nil {
+		tracing.RecordError(span, err)
 		logrus.WithContext(ctx).Errorf("error handling user message: %s", err.Error())
 
-		if err = session.Write([]byte(err.Error())); err != nil {
-			logrus.WithContext(ctx).Errorf("error writting message: %s", err.Error())
-		}
+		r.reply(session, err.Error())
+	}
+}
+
+// reply queues the text behind the connection's other messages, so it counts towards the queue and the
+// unsent messages like them and reaches the client in whichever protocol it talks
+func (r *Router) reply(session *melody.Session, text string) {
+	queuedConnection, ok := session.Get(keyQueuedConnection)
+	if !ok {
+		logrus.WithContext(sessionContext(session)).Error("error writting message: connection not found in melody session")
+		return
+	}
+
+	if err := queuedConnection.(communication.Connection).SendMessage([]byte(text)); err != nil {
+		logrus.WithContext(sessionContext(session)).Errorf("error writting message: %s", err.Error())
 	}
 }
 
 func (r *Router) HandleDisconnect(session *melody.Session) {
-	connectionID, ok := session.Get("connection_id")
+	ctx := sessionContext(session)
+
+	connectionID, ok := session.Get(logging.FieldConnectionID)
 	if !ok {
-		logrus.Errorf("error connection_id not found in melody session")
+		logrus.WithContext(ctx).Errorf("error connection_id not found in melody session")
 
 		if err := session.CloseWithMsg([]byte("connection ID not found, disconnecting")); err != nil {
-			logrus.Errorf("error closing with message: %s", err.Error())
+			logrus.WithContext(ctx).Errorf("error closing with message: %s", err.Error())
 		}
 
 		return
 	}
 
-	r.communicationService.HandleDisconnect(nil, connectionID.(string))
+	logrus.WithContext(ctx).Debug("websocket disconnected")
+
+	if stopConnection, ok := session.Get(keyStopConnection); ok {
+		stopConnection.(func())()
+	}
+
+	r.communicationService.HandleDisconnect(ctx, connectionID.(string))
 }
 
+// sessionContext creates a context carrying the session keys as log fields
+func sessionContext(session *melody.Session) context.Context {
+	fields := logrus.Fields{}
+	for _, key := range []string{logging.FieldConnectionID, logging.FieldConnectionIP, logging.FieldAPI} {
+		if value, ok := session.Get(key); ok {
+			fields[key] = value
+		}
+	}
+
+	return logging.WithFields(context.Background(), fields)
+}
+
+func (r *Router) HandleSentMessage(session *melody.Session, _ []byte) {
+	if connection, ok := session.Get(keyConnection); ok {
+		connection.(*melodySessionConnection).sent()
+	}
+}
+
+// negotiatedBinary reports whether the client asked for the binary protocol, the upgrader
+// agrees to it in the handshake as it is the only subprotocol on offer
+func negotiatedBinary(req *http.Request) bool {
+	for _, subprotocol := range websocket.Subprotocols(req) {
+		if subprotocol == protocol.Subprotocol {
+			return true
+		}
+	}
+
+	return false
+}
+
+const (
+	keyConnection       = "connection"
+	keyQueuedConnection = "queued_connection"
+	keyStopConnection   = "stop_connection"
+)
+
 type melodySessionConnection struct {
 	*melody.Session
+
+	// unsent holds a token for every message melody hasn't written to the socket yet, sending
+	// waits for a slow client rather than overflowing melody's buffer, which drops messages
+	unsent   chan struct{}
+	stopped  chan struct{}
+	stopOnce sync.Once
+
+	// encoder turns messages into binary protocol frames, nil for clients talking text
+	encoder binaryEncoder
 }
 
+type binaryEncoder interface {
+	Encode(message string) []protocol.Frame
+}
+
+var _ communication.Closer = (*melodySessionConnection)(nil)
+
+func newMelodySessionConnection(session *melody.Session, bufferSize int, binary bool) *melodySessionConnection {
+	c := &melodySessionConnection{
+		Session: session,
+		unsent:  make(chan struct{}, bufferSize),
+		stopped: make(chan struct{}),
+	}
+
+	if binary {
+		c.encoder = protocol.NewTextEncoder()
+	}
+
+	return c
+}
+
+// SendMessage is only called from the connection's queue, one message at a time, which keeps
+// the encoder's zombie names in step with what the client received
 func (c *melodySessionConnection) SendMessage(message []byte) error {
-	return c.Write(message)
+	if c.encoder == nil {
+		return c.write(message, c.Write)
+	}
+
+	for _, frame := range c.encoder.Encode(string(message)) {
+		if err := c.write(protocol.Marshal(frame), c.WriteBinary); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-func getUserIP(r *http.Request) string {
-	forwardedFor := r.Header.Get("X-Forwarded-For")
-	if forwardedFor != "" {
-		return strings.Split(forwardedFor, ",")[0]
+func (c *melodySessionConnection) write(message []byte, write func([]byte) error) error {
+	select {
+	case c.unsent <- struct{}{}:
+	case <-c.stopped:
+		return melody.ErrSessionClosed
 	}
 
-	// fallback to remote Address
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return ""
+	return write(message)
+}
+
+func (c *melodySessionConnection) sent() {
+	select {
+	case <-c.unsent:
+	default:
 	}
+}
+
+func (c *melodySessionConnection) stop() {
+	c.stopOnce.Do(func() {
+		close(c.stopped)
+	})
+}
 
-	return ip
+// Close disconnects a client which is too slow to keep up with its messages
+func (c *melodySessionConnection) Close() error {
+	return c.CloseWithMsg(melody.FormatCloseMessage(websocket.ClosePolicyViolation, "too slow"))
 }
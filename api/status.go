@@ -0,0 +1,87 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Version and Commit are meant to be set at build time, f.x.
+// go build -ldflags "-X github.com/ScruffyPants/talk-to-zombies/api.Version=v1.0.0"
+var (
+	Version = "dev"
+	Commit  = ""
+)
+
+// StatusProvider exposes live service state for the status endpoints
+type StatusProvider interface {
+	GameCount() int
+}
+
+type versionResponse struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	GoVersion string `json:"go_version"`
+	StartedAt string `json:"started_at"`
+	Uptime    string `json:"uptime"`
+	Games     int    `json:"games"`
+}
+
+func (r *Router) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (r *Router) handleReadyz(w http.ResponseWriter, _ *http.Request) {
+	switch {
+	case r.draining.Load():
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "draining"})
+	case !r.listening.Load():
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "starting"})
+	default:
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+	}
+}
+
+func (r *Router) handleVersion(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, versionResponse{
+		Version:   Version,
+		Commit:    buildCommit(),
+		GoVersion: runtime.Version(),
+		StartedAt: r.startedAt.UTC().Format(time.RFC3339),
+		Uptime:    time.Since(r.startedAt).Round(time.Second).String(),
+		Games:     r.statusProvider.GameCount(),
+	})
+}
+
+// buildCommit falls back to the VCS revision embedded by the go tool when Commit is not set
+func buildCommit() string {
+	if Commit != "" {
+		return Commit
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			return setting.Value
+		}
+	}
+
+	return ""
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		logrus.Errorf("error writing response: %s", err.Error())
+	}
+}
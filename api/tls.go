@@ -0,0 +1,253 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// certificateReloadCheckInterval limits how often handshakes check the certificate files for changes
+	certificateReloadCheckInterval = 5 * time.Second
+	selfSignedCertificateValidity  = 365 * 24 * time.Hour
+)
+
+type TLSSettings struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile enables mutual TLS, clients must present a certificate signed by one of its CAs
+	ClientCAFile string
+	// SelfSigned generates a throwaway certificate for local development
+	SelfSigned bool
+}
+
+// enabled counts any TLS setting, so a half configured server fails to start instead of
+// quietly serving plaintext
+func (s TLSSettings) enabled() bool {
+	return s.SelfSigned || s.CertFile != "" || s.KeyFile != "" || s.ClientCAFile != ""
+}
+
+// newTLSConfig returns nil when TLS is not enabled
+func newTLSConfig(settings TLSSettings) (*tls.Config, error) {
+	if !settings.enabled() {
+		return nil, nil
+	}
+
+	if settings.SelfSigned {
+		if settings.CertFile != "" || settings.KeyFile != "" || settings.ClientCAFile != "" {
+			return nil, fmt.Errorf("a self-signed certificate can't be combined with certificate, key or client CA files")
+		}
+
+		certificate, err := newSelfSignedCertificate()
+		if err != nil {
+			return nil, fmt.Errorf("error generating self-signed certificate: %w", err)
+		}
+
+		logrus.Warn("Serving with a self-signed certificate, do not use this outside of development")
+
+		return &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			NextProtos:   []string{"http/1.1"},
+			Certificates: []tls.Certificate{certificate},
+		}, nil
+	}
+
+	if settings.CertFile == "" || settings.KeyFile == "" {
+		if settings.ClientCAFile != "" {
+			return nil, fmt.Errorf("mutual TLS requires both TLS certificate and key files next to the client CA")
+		}
+
+		return nil, fmt.Errorf("both TLS certificate and key files are required")
+	}
+
+	reloader := &certificateReloader{
+		certFile:     settings.CertFile,
+		keyFile:      settings.KeyFile,
+		clientCAFile: settings.ClientCAFile,
+	}
+
+	if err := reloader.load(); err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		NextProtos:         []string{"http/1.1"},
+		GetConfigForClient: reloader.getConfigForClient,
+	}, nil
+}
+
+// certificateReloader serves the certificate and client CAs from disk and picks
+// up changed files without a restart
+type certificateReloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+
+	mu          sync.RWMutex
+	config      *tls.Config
+	modTimes    []time.Time
+	lastChecked time.Time
+}
+
+func (r *certificateReloader) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.reloadIfChanged()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.config, nil
+}
+
+func (r *certificateReloader) reloadIfChanged() {
+	r.mu.RLock()
+	recentlyChecked := time.Since(r.lastChecked) < certificateReloadCheckInterval
+	r.mu.RUnlock()
+
+	if recentlyChecked {
+		return
+	}
+
+	modTimes, err := r.fileModTimes()
+	if err != nil {
+		logrus.Errorf("error checking TLS files: %s", err.Error())
+		return
+	}
+
+	r.mu.Lock()
+	r.lastChecked = time.Now()
+	changed := !equalTimes(modTimes, r.modTimes)
+	r.mu.Unlock()
+
+	if !changed {
+		return
+	}
+
+	// Keeping the previous certificate if the new files are broken or only half written
+	if err = r.load(); err != nil {
+		logrus.Errorf("error reloading TLS certificate: %s", err.Error())
+		return
+	}
+
+	logrus.Infof("Reloaded TLS certificate from '%s'", r.certFile)
+}
+
+func (r *certificateReloader) load() error {
+	modTimes, err := r.fileModTimes()
+	if err != nil {
+		return err
+	}
+
+	certificate, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("error loading TLS certificate: %w", err)
+	}
+
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		NextProtos:   []string{"http/1.1"},
+		Certificates: []tls.Certificate{certificate},
+	}
+
+	if r.clientCAFile != "" {
+		caPEM, err := os.ReadFile(r.clientCAFile)
+		if err != nil {
+			return fmt.Errorf("error reading client CA file: %w", err)
+		}
+
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caPEM) {
+			return fmt.Errorf("no certificates found in client CA file '%s'", r.clientCAFile)
+		}
+
+		config.ClientCAs = clientCAs
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.config = config
+	r.modTimes = modTimes
+	r.lastChecked = time.Now()
+
+	return nil
+}
+
+func (r *certificateReloader) fileModTimes() ([]time.Time, error) {
+	files := []string{r.certFile, r.keyFile}
+	if r.clientCAFile != "" {
+		files = append(files, r.clientCAFile)
+	}
+
+	modTimes := make([]time.Time, 0, len(files))
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+
+		modTimes = append(modTimes, info.ModTime())
+	}
+
+	return modTimes, nil
+}
+
+func equalTimes(a, b []time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newSelfSignedCertificate() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	serialNumber, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{Organization: []string{"talk-to-zombies development"}},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(selfSignedCertificateValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1"), net.IPv6loopback},
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{certDER},
+		PrivateKey:  key,
+	}, nil
+}
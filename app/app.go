@@ -1,19 +1,35 @@
 package app
 
 import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 
 	"github.com/ScruffyPants/talk-to-zombies/api"
+	"github.com/ScruffyPants/talk-to-zombies/cluster"
 	"github.com/ScruffyPants/talk-to-zombies/communication"
 	"github.com/ScruffyPants/talk-to-zombies/game"
 	"github.com/ScruffyPants/talk-to-zombies/player"
+	"github.com/ScruffyPants/talk-to-zombies/tracing"
 )
 
+const shutdownTimeout = 10 * time.Second
+
 type app struct {
+	shutdownTracing func(context.Context) error
+
 	communicationService communication.Service
+	clusterNode          *cluster.Node
 	gameComponent        game.Component
 	playerComponent      player.Component
 	httpRouter           *api.Router
+	endlessRecords       game.RunStore
+
+	drainPeriod time.Duration
 }
 
 func NewApp() (*app, error) {
@@ -21,38 +37,239 @@ func NewApp() (*app, error) {
 		return nil, err
 	}
 
+	shutdownTracing, err := tracing.Setup(tracing.Settings{
+		Exporter:     viper.GetString("tracing.exporter"),
+		OTLPEndpoint: viper.GetString("tracing.otlp.endpoint"),
+		OTLPInsecure: viper.GetBool("tracing.otlp.insecure"),
+		SampleRatio:  viper.GetFloat64("tracing.sampleratio"),
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	communicationService := communication.NewCommunicationService()
 
-	playerComponent := player.NewPlayerComponent()
+	var usernameFilter player.UsernameFilter
+	if denyListPath := viper.GetString("username.denylist"); denyListPath != "" {
+		usernameFilter, err = player.NewDenyListFilterFromFile(denyListPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	playerComponent, err := player.NewPlayerComponent(player.Settings{
+		MinUsernameLength:        viper.GetInt("username.minlength"),
+		MaxUsernameLength:        viper.GetInt("username.maxlength"),
+		SuffixDuplicateUsernames: viper.GetString("username.duplicates") == "suffix",
+	}, usernameFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	clusterSettings := cluster.Settings{
+		Backend:      viper.GetString("cluster.backend"),
+		NodeID:       viper.GetString("cluster.node"),
+		RedisAddress: viper.GetString("cluster.redis.address"),
+		GameTTL:      viper.GetDuration("cluster.gamettl"),
+	}
+	if clusterSettings.NodeID == "" {
+		clusterSettings.NodeID = uuid.NewString()
+	}
+
+	clusterBackend, err := cluster.NewBackend(clusterSettings)
+	if err != nil {
+		return nil, err
+	}
+
+	clusterNode, err := cluster.NewClusterNode(clusterSettings, clusterBackend, communicationService)
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshotStore game.SnapshotStore
+	if snapshotPath := viper.GetString("snapshot.path"); snapshotPath != "" {
+		snapshotStore = game.NewFileSnapshotStore(snapshotPath)
+	}
+
+	var zombieTypes []game.ZombieType
+	if err = viper.UnmarshalKey("zombie.types", &zombieTypes); err != nil {
+		return nil, err
+	}
+	if len(zombieTypes) == 0 {
+		zombieTypes = game.DefaultZombieTypes(viper.GetDuration("zombie.interval"))
+	}
+
+	zombieTypeRegistry, err := game.NewZombieTypeRegistry(zombieTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	visibilityMode, err := game.ParseVisibilityMode(viper.GetString("visibility.mode"))
+	if err != nil {
+		return nil, err
+	}
+
+	var endlessRecords game.RunStore = game.NewMemoryRunStore()
+	if recordsPath := viper.GetString("endless.records"); recordsPath != "" {
+		if endlessRecords, err = game.NewFileRunStore(recordsPath); err != nil {
+			return nil, err
+		}
+	}
+
+	var chatFilter game.TextFilter
+	if denyListPath := viper.GetString("chat.denylist"); denyListPath != "" {
+		if chatFilter, err = game.NewChatFilterFromFile(denyListPath); err != nil {
+			return nil, err
+		}
+	}
 
 	gameSettings := game.Settings{
 		ZombieCoordinateUpdateInterval: viper.GetDuration("zombie.interval"),
+		SnapshotInterval:               viper.GetDuration("snapshot.interval"),
+		SnapshotRejoinTimeout:          viper.GetDuration("snapshot.rejointimeout"),
+		ZombieTypes:                    zombieTypeRegistry,
+		Weapons: game.WeaponSettings{
+			GrenadeRadius: viper.GetInt("weapon.grenade.radius"),
+			GrenadeDelay:  viper.GetDuration("weapon.grenade.delay"),
+			GrenadeCount:  viper.GetInt("weapon.grenade.count"),
+		},
+		Visibility: game.VisibilitySettings{
+			Mode:         visibilityMode,
+			Range:        viper.GetInt("visibility.range"),
+			SectorSize:   viper.GetInt("visibility.sectorsize"),
+			ScanCooldown: viper.GetDuration("scan.cooldown"),
+			ScanDuration: viper.GetDuration("scan.duration"),
+		},
+		WallHealth: viper.GetInt("wall.health"),
+		Rules: game.RuleBounds{
+			MinZombies:    viper.GetInt("rules.zombies.min"),
+			MaxZombies:    viper.GetInt("rules.zombies.max"),
+			MinInterval:   viper.GetDuration("rules.interval.min"),
+			MaxInterval:   viper.GetDuration("rules.interval.max"),
+			MinWidth:      viper.GetInt("rules.width.min"),
+			MaxWidth:      viper.GetInt("rules.width.max"),
+			MinWallHealth: viper.GetInt("rules.wall.min"),
+			MaxWallHealth: viper.GetInt("rules.wall.max"),
+		},
+		Endless: game.EndlessSettings{
+			FirstWaveSize:   viper.GetInt("endless.firstwave"),
+			WaveGrowth:      viper.GetInt("endless.wavegrowth"),
+			SpeedUp:         viper.GetFloat64("endless.speedup"),
+			MinMoveInterval: viper.GetDuration("endless.mininterval"),
+			ToughnessEvery:  viper.GetInt("endless.toughnessevery"),
+			Records:         endlessRecords,
+		},
+		Modes: game.ModeSettings{
+			CoopWaves:     viper.GetInt("mode.coop.waves"),
+			CoopWaveSize:  viper.GetInt("mode.coop.wavesize"),
+			FFAKillTarget: viper.GetInt("mode.ffa.target"),
+		},
+		Chat: game.ChatSettings{
+			MaxLength:  viper.GetInt("chat.maxlength"),
+			RateLimit:  viper.GetInt("chat.ratelimit"),
+			RatePeriod: viper.GetDuration("chat.rateperiod"),
+			Filter:     chatFilter,
+		},
+		Commands: game.CommandSettings{
+			RateLimit:  viper.GetInt("commands.ratelimit"),
+			RatePeriod: viper.GetDuration("commands.rateperiod"),
+		},
+		FanOut: game.FanOutSettings{
+			Workers: viper.GetInt("fanout.workers"),
+		},
 	}
-	gameComponent, err := game.NewGameComponent(playerComponent, communicationService, gameSettings)
+	gameComponent, err := game.NewGameComponent(playerComponent, communicationService, clusterNode, snapshotStore, gameSettings)
 	if err != nil {
 		return nil, err
 	}
 
-	communicationService.AddListener(gameComponent)
+	clusterNode.SetLocalListener(gameComponent)
+	communicationService.AddListener(clusterNode)
 
-	httpRouter := api.NewRouter(
+	overflowPolicy, err := communication.ParseOverflowPolicy(viper.GetString("ws.overflow"))
+	if err != nil {
+		return nil, err
+	}
+
+	httpRouter, err := api.NewRouter(
 		api.RouterSettings{
-			Address:      viper.GetString("address"),
-			PingInterval: viper.GetDuration("ws.pinginterval"),
-			PongWait:     viper.GetDuration("ws.pongwait"),
-			WriteWait:    viper.GetDuration("ws.writewait"),
+			Address:       viper.GetString("address"),
+			AdminAddress:  viper.GetString("admin.address"),
+			WebsocketPath: viper.GetString("ws.path"),
+			PingInterval:  viper.GetDuration("ws.pinginterval"),
+			PongWait:      viper.GetDuration("ws.pongwait"),
+			WriteWait:     viper.GetDuration("ws.writewait"),
+			DrainPeriod:   viper.GetDuration("http.drainperiod"),
+			TLS: api.TLSSettings{
+				CertFile:     viper.GetString("tls.cert"),
+				KeyFile:      viper.GetString("tls.key"),
+				ClientCAFile: viper.GetString("tls.clientca"),
+				SelfSigned:   viper.GetBool("tls.selfsigned"),
+			},
+			AllowedOrigins:      viper.GetStringSlice("http.allowedorigins"),
+			TrustedProxies:      viper.GetStringSlice("http.trustedproxies"),
+			IPBans:              viper.GetStringSlice("ip.bans"),
+			MaxConnectionsPerIP: viper.GetInt("ip.maxconnections"),
+			Outbound: communication.QueueSettings{
+				Size:         viper.GetInt("ws.queuesize"),
+				Policy:       overflowPolicy,
+				MaxOverflows: viper.GetInt("ws.maxoverflows"),
+				Droppable:    game.IsPositionUpdate,
+			},
 		},
 		communicationService,
+		gameComponent,
 	)
+	if err != nil {
+		return nil, err
+	}
+
+	watchConfig(func() {
+		if err := httpRouter.UpdateIPFilter(viper.GetStringSlice("ip.bans"), viper.GetInt("ip.maxconnections")); err != nil {
+			logrus.Errorf("error reloading ip filter: %s", err.Error())
+		}
+	})
 
 	return &app{
+		shutdownTracing: shutdownTracing,
+
 		communicationService: communicationService,
+		clusterNode:          clusterNode,
 		gameComponent:        gameComponent,
 		playerComponent:      playerComponent,
 		httpRouter:           httpRouter,
+		endlessRecords:       endlessRecords,
+
+		drainPeriod: viper.GetDuration("http.drainperiod"),
 	}, nil
 }
 
 func (a *app) Start() {
 	a.httpRouter.Start()
 }
+
+func (a *app) Stop() error {
+	// The drain period comes on top of the time the shutdown itself may take
+	ctx, cancel := context.WithTimeout(context.Background(), a.drainPeriod+shutdownTimeout)
+	defer cancel()
+
+	if err := a.httpRouter.Shutdown(ctx); err != nil {
+		logrus.Errorf("error shutting down http server: %s", err.Error())
+	}
+
+	snapshotErr := a.gameComponent.SaveSnapshots()
+	a.clusterNode.Close(ctx)
+
+	// The file store writes new records in the background
+	if closer, ok := a.endlessRecords.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			logrus.Errorf("error closing endless records: %s", err.Error())
+		}
+	}
+
+	if err := a.shutdownTracing(ctx); err != nil {
+		return err
+	}
+
+	return snapshotErr
+}
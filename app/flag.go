@@ -4,17 +4,84 @@ import (
 	"strings"
 	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+
+	"github.com/ScruffyPants/talk-to-zombies/logging"
 )
 
 func ParseFlags() error {
 	pflag.Duration("zombie.interval", 2*time.Second, "Zombie coordinate update interval")
+	pflag.Int("weapon.grenade.radius", 2, "Radius of grenade explosions")
+	pflag.Duration("weapon.grenade.delay", time.Second, "Time between throwing a grenade and its explosion")
+	pflag.Int("weapon.grenade.count", 3, "Grenades each player has per game, 0 means unlimited")
+	pflag.String("visibility.mode", "off", "How zombies far from the wall are reported (off, approximate, hidden)")
+	pflag.Int("visibility.range", 10, "Distance from the wall within which zombies are always visible")
+	pflag.Int("visibility.sectorsize", 5, "Size of the sectors approximate zombie positions are reported in")
+	pflag.Duration("scan.cooldown", 15*time.Second, "Time a player waits between scans")
+	pflag.Duration("scan.duration", 3*time.Second, "Time a scan reveals every zombie for")
+	pflag.Int("wall.health", 3, "Damage the wall withstands before the game is lost, every zombie reaching it deals its damage")
+	pflag.Int("mode.coop.waves", 3, "Waves to clear to win a coop game")
+	pflag.Int("mode.coop.wavesize", 2, "Zombies in the first coop wave, every wave adds as many")
+	pflag.Int("mode.ffa.target", 10, "Kills needed to win a free-for-all game")
+	pflag.Int("endless.firstwave", 3, "Zombies in the first wave of endless games")
+	pflag.Int("endless.wavegrowth", 2, "Zombies every endless wave adds")
+	pflag.Float64("endless.speedup", 0.9, "Move interval multiplier of every endless wave")
+	pflag.Duration("endless.mininterval", 300*time.Millisecond, "Shortest move interval endless waves speed up to")
+	pflag.Int("endless.toughnessevery", 3, "Endless waves after which zombies get an extra hit point, 0 never")
+	pflag.String("endless.records", "", "File the best endless run of every username is stored in, kept in memory if empty")
+	pflag.Int("rules.zombies.min", 1, "Fewest zombies players may start their games with")
+	pflag.Int("rules.zombies.max", 20, "Most zombies players may start their games with")
+	pflag.Duration("rules.interval.min", 200*time.Millisecond, "Shortest zombie interval players may pick for their games")
+	pflag.Duration("rules.interval.max", 10*time.Second, "Longest zombie interval players may pick for their games")
+	pflag.Int("rules.width.min", 5, "Narrowest board players may pick for their games")
+	pflag.Int("rules.width.max", 50, "Widest board players may pick for their games")
+	pflag.Int("rules.wall.min", 1, "Lowest wall health players may pick for their games")
+	pflag.Int("rules.wall.max", 20, "Highest wall health players may pick for their games")
+	pflag.Int("chat.maxlength", 200, "Longest chat message in characters")
+	pflag.Int("chat.ratelimit", 5, "Chat messages a player may send per chat.rateperiod, 0 means unlimited")
+	pflag.Duration("chat.rateperiod", 10*time.Second, "Period the chat rate limit applies to")
+	pflag.Int("commands.ratelimit", 200, "Commands a connection may send per commands.rateperiod, 0 means unlimited")
+	pflag.Duration("commands.rateperiod", time.Second, "Period the command rate limit applies to")
+	pflag.Int("fanout.workers", 8, "Number of workers sending game messages to connections")
+	pflag.String("chat.denylist", "", "File with words or phrases not allowed in chat messages, one per line")
 	pflag.String("address", ":8082", "HTTP server address")
+	pflag.String("admin.address", "localhost:8088", "Address /debug/vars is served on, apart from the public address, disabled if empty")
+	pflag.String("tls.cert", "", "TLS certificate file, enables TLS together with --tls.key")
+	pflag.String("tls.key", "", "TLS private key file")
+	pflag.String("tls.clientca", "", "CA bundle used to verify client certificates, enables mutual TLS")
+	pflag.Bool("tls.selfsigned", false, "Serve TLS with a generated self-signed certificate, for development only")
+	pflag.StringSlice("http.allowedorigins", nil, "Cross-site origins allowed to open websockets and call HTTP endpoints, f.x. https://*.example.com")
+	pflag.StringSlice("http.trustedproxies", nil, "IPs and CIDRs of proxies whose Forwarded and X-Forwarded-For headers are trusted")
+	pflag.Duration("http.drainperiod", 5*time.Second, "Time /readyz reports draining on shutdown before new connections are refused")
+	pflag.StringSlice("ip.bans", nil, "IPs and CIDRs refused from connecting, reloaded when the config file changes")
+	pflag.Int("ip.maxconnections", 0, "Maximum open websocket connections per client IP, 0 means unlimited")
+	pflag.String("ws.path", "/ws", "HTTP path of the websocket endpoint")
 	pflag.Duration("ws.pinginterval", 10*time.Second, "Ping interval for websocket connections")
 	pflag.Duration("ws.pongwait", 20*time.Second, "Pong wait for websocket connections")
 	pflag.Duration("ws.writewait", 20*time.Second, "Write wait for websocket connections")
+	pflag.Int("ws.queuesize", 256, "Messages waiting for a websocket connection before it overflows")
+	pflag.String("ws.overflow", "drop", "What to do when a connection overflows: drop (zombie moves first) or disconnect")
+	pflag.Int("ws.maxoverflows", 32, "Overflows in a row after which the disconnect policy closes the connection")
+	pflag.String("cluster.backend", "memory", "Cluster backend used to share games between nodes (memory, redis)")
+	pflag.String("cluster.node", "", "ID of this node in the cluster, generated if empty")
+	pflag.Duration("cluster.gamettl", 30*time.Second, "How long a crashed node's games stay claimed, the claims are renewed while the node runs")
+	pflag.String("cluster.redis.address", "localhost:6379", "Address of the Redis protocol server used by the redis cluster backend")
+	pflag.String("snapshot.path", "", "File game snapshots are stored in and restored from on boot, snapshots are disabled if empty")
+	pflag.Duration("snapshot.interval", 10*time.Second, "Interval between game snapshots")
+	pflag.Duration("snapshot.rejointimeout", 10*time.Minute, "Restored games nobody rejoined within this time are removed, 0 keeps them")
+	pflag.String("log.level", "info", "Log level (trace, debug, info, warn, error, fatal, panic)")
+	pflag.String("log.format", "text", "Log output format (text, json)")
+	pflag.String("tracing.exporter", "none", "OpenTelemetry trace exporter (none, stdout, otlp)")
+	pflag.String("tracing.otlp.endpoint", "localhost:4318", "OTLP/HTTP collector endpoint used by the otlp exporter")
+	pflag.Bool("tracing.otlp.insecure", false, "Send traces to the OTLP collector over plain HTTP")
+	pflag.Float64("tracing.sampleratio", 1, "Ratio of traces sampled, between 0 and 1")
+	pflag.Int("username.minlength", 2, "Minimum username length")
+	pflag.Int("username.maxlength", 32, "Maximum username length")
+	pflag.String("username.duplicates", "reject", "What happens to a player joining with a username taken in the game (reject, suffix)")
+	pflag.String("username.denylist", "", "File with words not allowed in usernames, one per line")
 	pflag.String("config", "config.local", "Name of the config file")
 
 	configName, err := pflag.CommandLine.GetString("config")
@@ -41,5 +108,21 @@ func ParseFlags() error {
 		return err
 	}
 
-	return nil
+	return logging.Setup(logging.Settings{
+		Level:  viper.GetString("log.level"),
+		Format: viper.GetString("log.format"),
+	})
+}
+
+// watchConfig calls onChange whenever the loaded config file changes
+func watchConfig(onChange func()) {
+	if viper.ConfigFileUsed() == "" {
+		return
+	}
+
+	viper.OnConfigChange(func(e fsnotify.Event) {
+		logrus.Infof("Config file '%s' changed, reloading", e.Name)
+		onChange()
+	})
+	viper.WatchConfig()
 }
@@ -0,0 +1,49 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrGameNotFound = fmt.Errorf("game not found in cluster")
+	ErrNodeNotFound = fmt.Errorf("node not found in cluster")
+	ErrNodeBusy     = fmt.Errorf("node is not keeping up with its messages")
+)
+
+// Backend is the state shared between all nodes of a cluster: which node owns
+// which game and a channel per node to pass envelopes around.
+type Backend interface {
+	ClaimGame(ctx context.Context, gameID string, nodeID string) (bool, error)
+	// RenewGame keeps a claim from expiring, it returns false if the node no longer owns the game
+	RenewGame(ctx context.Context, gameID string, nodeID string) (bool, error)
+	GetGameOwner(ctx context.Context, gameID string) (string, error)
+	// ReleaseGame gives the game up if the node still owns it, a claim which went to another node is left alone
+	ReleaseGame(ctx context.Context, gameID string, nodeID string) error
+
+	Publish(ctx context.Context, nodeID string, envelope Envelope) error
+	Subscribe(ctx context.Context, nodeID string) (<-chan Envelope, error)
+
+	Close() error
+}
+
+type Settings struct {
+	Backend      string
+	NodeID       string
+	RedisAddress string
+	// GameTTL is how long a claim on a game outlives the node which stopped renewing it,
+	// f.x. because it crashed, 0 keeps claims until they are released
+	GameTTL time.Duration
+}
+
+func NewBackend(settings Settings) (Backend, error) {
+	switch settings.Backend {
+	case "", "memory":
+		return NewMemoryBackend(), nil
+	case "redis":
+		return NewRedisBackend(settings.RedisAddress, settings.GameTTL), nil
+	default:
+		return nil, fmt.Errorf("cluster backend %s is not supported", settings.Backend)
+	}
+}
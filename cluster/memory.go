@@ -0,0 +1,91 @@
+package cluster
+
+import (
+	"context"
+	"time"
+
+	cmap "github.com/orcaman/concurrent-map/v2"
+)
+
+const (
+	memorySubscriptionBufferSize = 256
+	// memoryPublishTimeout bounds the wait for room in a subscription, nodes publish from their
+	// listen loops and two of them waiting on each other would otherwise never recover
+	memoryPublishTimeout = time.Second
+)
+
+type memoryBackend struct {
+	gameOwners    cmap.ConcurrentMap[string, string]
+	subscriptions cmap.ConcurrentMap[string, chan Envelope]
+}
+
+var _ Backend = (*memoryBackend)(nil)
+
+// NewMemoryBackend creates a backend which only spans the current process, nodes
+// sharing the same instance behave as if they were separate servers.
+func NewMemoryBackend() *memoryBackend {
+	return &memoryBackend{
+		gameOwners:    cmap.New[string](),
+		subscriptions: cmap.New[chan Envelope](),
+	}
+}
+
+func (b *memoryBackend) ClaimGame(_ context.Context, gameID string, nodeID string) (bool, error) {
+	return b.gameOwners.SetIfAbsent(gameID, nodeID), nil
+}
+
+func (b *memoryBackend) RenewGame(_ context.Context, gameID string, nodeID string) (bool, error) {
+	owner, ok := b.gameOwners.Get(gameID)
+	return ok && owner == nodeID, nil
+}
+
+func (b *memoryBackend) GetGameOwner(_ context.Context, gameID string) (string, error) {
+	nodeID, ok := b.gameOwners.Get(gameID)
+	if !ok {
+		return "", ErrGameNotFound
+	}
+
+	return nodeID, nil
+}
+
+func (b *memoryBackend) ReleaseGame(_ context.Context, gameID string, nodeID string) error {
+	b.gameOwners.RemoveCb(gameID, func(_ string, owner string, exists bool) bool {
+		return exists && owner == nodeID
+	})
+	return nil
+}
+
+func (b *memoryBackend) Publish(ctx context.Context, nodeID string, envelope Envelope) error {
+	subscription, ok := b.subscriptions.Get(nodeID)
+	if !ok {
+		return ErrNodeNotFound
+	}
+
+	timer := time.NewTimer(memoryPublishTimeout)
+	defer timer.Stop()
+
+	select {
+	case subscription <- envelope:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return ErrNodeBusy
+	}
+}
+
+func (b *memoryBackend) Subscribe(_ context.Context, nodeID string) (<-chan Envelope, error) {
+	subscription := make(chan Envelope, memorySubscriptionBufferSize)
+	b.subscriptions.Set(nodeID, subscription)
+
+	return subscription, nil
+}
+
+func (b *memoryBackend) Close() error {
+	for nodeID, subscription := range b.subscriptions.Items() {
+		b.subscriptions.Remove(nodeID)
+		close(subscription)
+	}
+
+	return nil
+}
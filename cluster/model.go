@@ -0,0 +1,26 @@
+package cluster
+
+import (
+	"github.com/ScruffyPants/talk-to-zombies/communication"
+)
+
+type EnvelopeType string
+
+const (
+	// EnvelopeMessage carries a command from the node holding the connection to the node owning the game
+	EnvelopeMessage EnvelopeType = "message"
+	// EnvelopeDisconnect notifies the node owning the game that a forwarded connection went away
+	EnvelopeDisconnect EnvelopeType = "disconnect"
+	// EnvelopeDeliver carries an outbound message from the node owning the game back to the connection's node
+	EnvelopeDeliver EnvelopeType = "deliver"
+)
+
+type Envelope struct {
+	Type         EnvelopeType          `json:"type"`
+	SourceNodeID string                `json:"source_node_id"`
+	ConnectionID string                `json:"connection_id"`
+	Message      communication.Message `json:"message,omitempty"`
+	Payload      []byte                `json:"payload,omitempty"`
+	// TraceContext continues the sender's trace on the receiving node
+	TraceContext map[string]string `json:"trace_context,omitempty"`
+}
@@ -0,0 +1,261 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	cmap "github.com/orcaman/concurrent-map/v2"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ScruffyPants/talk-to-zombies/communication"
+	"github.com/ScruffyPants/talk-to-zombies/logging"
+	"github.com/ScruffyPants/talk-to-zombies/tracing"
+)
+
+// Node sits between the communication service and the local game listener. Games
+// are owned by the node they were started on, connections which joined a game
+// owned by a different node get their messages forwarded to the owner, which in
+// turn sends its replies back through the backend.
+type Node struct {
+	id                   string
+	backend              Backend
+	communicationService communication.Service
+	localListener        communication.Listener
+
+	// connectionRoutes maps local connection IDs to the node owning their game
+	connectionRoutes cmap.ConcurrentMap[string, string]
+	// ownedGames are the games this node claimed, their claims are renewed until released
+	ownedGames cmap.ConcurrentMap[string, struct{}]
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+var _ communication.Listener = (*Node)(nil)
+
+func NewClusterNode(settings Settings, backend Backend, communicationService communication.Service) (*Node, error) {
+	n := &Node{
+		id:                   settings.NodeID,
+		backend:              backend,
+		communicationService: communicationService,
+		connectionRoutes:     cmap.New[string](),
+		ownedGames:           cmap.New[struct{}](),
+		closed:               make(chan struct{}),
+	}
+
+	subscription, err := backend.Subscribe(context.Background(), settings.NodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	go n.listen(subscription)
+
+	if settings.GameTTL > 0 {
+		// Renewing a few times per TTL keeps the claims alive through a failed renewal
+		go n.heartbeat(settings.GameTTL / 3)
+	}
+
+	return n, nil
+}
+
+// Close stops renewing the claims on the node's games and releases them, so a restarted
+// node (whose ID may have changed) can claim them again when restoring its snapshots
+func (n *Node) Close(ctx context.Context) {
+	n.closeOnce.Do(func() {
+		close(n.closed)
+	})
+
+	for _, gameID := range n.ownedGames.Keys() {
+		n.ReleaseGame(ctx, gameID)
+	}
+}
+
+func (n *Node) ID() string {
+	return n.id
+}
+
+// SetLocalListener sets the listener which receives messages for games owned by this node
+func (n *Node) SetLocalListener(listener communication.Listener) {
+	n.localListener = listener
+}
+
+func (n *Node) OnMessage(ctx context.Context, connectionID string, message communication.Message) {
+	ownerNodeID, ok := n.connectionRoutes.Get(connectionID)
+	if !ok {
+		n.localListener.OnMessage(ctx, connectionID, message)
+		return
+	}
+
+	if err := n.forwardMessage(ctx, ownerNodeID, connectionID, message); err != nil {
+		logrus.WithContext(ctx).Errorf("error forwarding message to node %s: %s", ownerNodeID, err.Error())
+	}
+}
+
+// forwardMessage publishes the message to the node owning the connection's game, the route
+// is dropped if that node is gone
+func (n *Node) forwardMessage(ctx context.Context, ownerNodeID string, connectionID string, message communication.Message) error {
+	ctx, span := tracing.Tracer().Start(ctx, "cluster.ForwardMessage", trace.WithAttributes(
+		tracing.AttributeNodeID.String(ownerNodeID),
+	))
+	defer span.End()
+
+	err := n.backend.Publish(ctx, ownerNodeID, Envelope{
+		Type:         EnvelopeMessage,
+		SourceNodeID: n.id,
+		ConnectionID: connectionID,
+		Message:      message,
+		TraceContext: tracing.Inject(ctx),
+	})
+	if err != nil {
+		tracing.RecordError(span, err)
+
+		if errors.Is(err, ErrNodeNotFound) {
+			n.connectionRoutes.Remove(connectionID)
+		}
+	}
+
+	return err
+}
+
+func (n *Node) OnDisconnect(ctx context.Context, connectionID string) {
+	ownerNodeID, ok := n.connectionRoutes.Pop(connectionID)
+	if !ok {
+		n.localListener.OnDisconnect(ctx, connectionID)
+		return
+	}
+
+	if err := n.backend.Publish(ctx, ownerNodeID, Envelope{
+		Type:         EnvelopeDisconnect,
+		SourceNodeID: n.id,
+		ConnectionID: connectionID,
+		TraceContext: tracing.Inject(ctx),
+	}); err != nil {
+		logrus.WithContext(ctx).Errorf("error forwarding disconnect to node %s: %s", ownerNodeID, err.Error())
+	}
+}
+
+func (n *Node) ClaimGame(ctx context.Context, gameID string) (bool, error) {
+	claimed, err := n.backend.ClaimGame(ctx, gameID, n.id)
+	if claimed {
+		n.ownedGames.Set(gameID, struct{}{})
+	}
+
+	return claimed, err
+}
+
+func (n *Node) ReleaseGame(ctx context.Context, gameID string) {
+	n.ownedGames.Remove(gameID)
+
+	if err := n.backend.ReleaseGame(ctx, gameID, n.id); err != nil {
+		logrus.WithContext(ctx).Errorf("error releasing game %s: %s", gameID, err.Error())
+	}
+}
+
+// ForwardToGame routes the connection to the node owning the game and forwards the message there,
+// returns false if the game is not owned by another node
+func (n *Node) ForwardToGame(ctx context.Context, gameID string, connectionID string, message communication.Message) (bool, error) {
+	ownerNodeID, err := n.backend.GetGameOwner(ctx, gameID)
+	if errors.Is(err, ErrGameNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if ownerNodeID == n.id {
+		return false, nil
+	}
+
+	n.connectionRoutes.Set(connectionID, ownerNodeID)
+	if err = n.forwardMessage(ctx, ownerNodeID, connectionID, message); err != nil {
+		n.connectionRoutes.Remove(connectionID)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// heartbeat renews the claims on the node's games, so they only expire once the node is gone
+func (n *Node) heartbeat(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-n.closed:
+			return
+		case <-ticker.C:
+		}
+
+		for _, gameID := range n.ownedGames.Keys() {
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			owned, err := n.backend.RenewGame(ctx, gameID, n.id)
+			cancel()
+
+			if err != nil {
+				logrus.Errorf("error renewing claim on game %s: %s", gameID, err.Error())
+				continue
+			}
+
+			if !owned {
+				logrus.Warnf("lost the claim on game %s", gameID)
+				n.ownedGames.Remove(gameID)
+			}
+		}
+	}
+}
+
+func (n *Node) listen(subscription <-chan Envelope) {
+	for envelope := range subscription {
+		n.handleEnvelope(envelope)
+	}
+}
+
+func (n *Node) handleEnvelope(envelope Envelope) {
+	ctx := logging.WithFields(context.Background(), logrus.Fields{
+		logging.FieldConnectionID: envelope.ConnectionID,
+		logging.FieldNodeID:       envelope.SourceNodeID,
+	})
+	ctx = tracing.Extract(ctx, envelope.TraceContext)
+
+	switch envelope.Type {
+	case EnvelopeMessage:
+		n.communicationService.RegisterConnection(envelope.ConnectionID, &remoteConnection{
+			node:         n,
+			nodeID:       envelope.SourceNodeID,
+			connectionID: envelope.ConnectionID,
+		})
+
+		if err := n.communicationService.HandleMessage(ctx, envelope.ConnectionID, envelope.Message); err != nil {
+			logrus.WithContext(ctx).Errorf("error handling forwarded message: %s", err.Error())
+		}
+	case EnvelopeDisconnect:
+		n.communicationService.HandleDisconnect(ctx, envelope.ConnectionID)
+	case EnvelopeDeliver:
+		if err := n.communicationService.SendMessageToConnection(ctx, envelope.ConnectionID, envelope.Payload); err != nil {
+			logrus.WithContext(ctx).Errorf("error delivering forwarded message: %s", err.Error())
+		}
+	default:
+		logrus.WithContext(ctx).Errorf("cluster envelope type %s is not supported", envelope.Type)
+	}
+}
+
+// remoteConnection is a connection held by a different node, messages sent to it are
+// published to that node
+type remoteConnection struct {
+	node         *Node
+	nodeID       string
+	connectionID string
+}
+
+func (c *remoteConnection) SendMessage(message []byte) error {
+	return c.node.backend.Publish(context.Background(), c.nodeID, Envelope{
+		Type:         EnvelopeDeliver,
+		SourceNodeID: c.node.id,
+		ConnectionID: c.connectionID,
+		Payload:      message,
+	})
+}
@@ -0,0 +1,324 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	redisKeyPrefix           = "talk-to-zombies:"
+	redisResubscribeInterval = time.Second
+	// redisPoolSize is the number of commands in flight at once, each on a connection of its own,
+	// so a slow reply only holds up its own caller
+	redisPoolSize = 4
+)
+
+// redisReleaseScript deletes a game's claim only if it is still held by the releasing node
+const redisReleaseScript = `if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) else return 0 end`
+
+// redisBackend keeps game ownership in string keys and passes envelopes over
+// pub/sub channels, anything speaking the Redis protocol can act as the server
+type redisBackend struct {
+	address string
+	gameTTL time.Duration
+
+	// slots limits the connections commands are sent on, idleConns are the ones waiting for the next command
+	slots             chan struct{}
+	mu                sync.Mutex
+	idleConns         []*respConn
+	subscriptionConns map[*respConn]struct{}
+
+	closed chan struct{}
+}
+
+var _ Backend = (*redisBackend)(nil)
+
+func NewRedisBackend(address string, gameTTL time.Duration) *redisBackend {
+	return &redisBackend{
+		address:           address,
+		gameTTL:           gameTTL,
+		slots:             make(chan struct{}, redisPoolSize),
+		subscriptionConns: map[*respConn]struct{}{},
+		closed:            make(chan struct{}),
+	}
+}
+
+func (b *redisBackend) ClaimGame(ctx context.Context, gameID string, nodeID string) (bool, error) {
+	args := []string{"SET", gameKey(gameID), nodeID, "NX"}
+	if b.gameTTL > 0 {
+		args = append(args, "PX", strconv.FormatInt(b.gameTTL.Milliseconds(), 10))
+	}
+
+	reply, err := b.do(ctx, args...)
+	if err != nil {
+		return false, err
+	}
+
+	return reply != nil, nil
+}
+
+// RenewGame extends the claim if the node still owns the game. Between the GET and the PEXPIRE
+// the claim may expire and go to another node, which then merely keeps it a little longer.
+func (b *redisBackend) RenewGame(ctx context.Context, gameID string, nodeID string) (bool, error) {
+	owner, err := b.GetGameOwner(ctx, gameID)
+	if errors.Is(err, ErrGameNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if owner != nodeID {
+		return false, nil
+	}
+
+	if b.gameTTL <= 0 {
+		return true, nil
+	}
+
+	reply, err := b.do(ctx, "PEXPIRE", gameKey(gameID), strconv.FormatInt(b.gameTTL.Milliseconds(), 10))
+	if err != nil {
+		return false, err
+	}
+
+	// PEXPIRE replies 0 if the key expired in the meantime
+	return reply == int64(1), nil
+}
+
+func (b *redisBackend) GetGameOwner(ctx context.Context, gameID string) (string, error) {
+	reply, err := b.do(ctx, "GET", gameKey(gameID))
+	if err != nil {
+		return "", err
+	}
+
+	if reply == nil {
+		return "", ErrGameNotFound
+	}
+
+	nodeID, ok := reply.(string)
+	if !ok {
+		return "", fmt.Errorf("unexpected reply to GET: %v", reply)
+	}
+
+	return nodeID, nil
+}
+
+// ReleaseGame compares and deletes in a script, so a claim which expired and went to another node survives
+func (b *redisBackend) ReleaseGame(ctx context.Context, gameID string, nodeID string) error {
+	_, err := b.do(ctx, "EVAL", redisReleaseScript, "1", gameKey(gameID), nodeID)
+	return err
+}
+
+func (b *redisBackend) Publish(ctx context.Context, nodeID string, envelope Envelope) error {
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+
+	reply, err := b.do(ctx, "PUBLISH", nodeChannel(nodeID), string(payload))
+	if err != nil {
+		return err
+	}
+
+	if receivers, ok := reply.(int64); ok && receivers == 0 {
+		return ErrNodeNotFound
+	}
+
+	return nil
+}
+
+func (b *redisBackend) Subscribe(ctx context.Context, nodeID string) (<-chan Envelope, error) {
+	conn, err := b.subscribe(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	subscription := make(chan Envelope, memorySubscriptionBufferSize)
+
+	go func() {
+		defer close(subscription)
+
+		for {
+			b.readSubscription(conn, subscription)
+			b.closeSubscriptionConn(conn)
+
+			for {
+				select {
+				case <-b.closed:
+					return
+				case <-time.After(redisResubscribeInterval):
+				}
+
+				if conn, err = b.subscribe(context.Background(), nodeID); err == nil {
+					break
+				}
+
+				logrus.Errorf("error resubscribing to cluster channel: %s", err.Error())
+			}
+		}
+	}()
+
+	return subscription, nil
+}
+
+func (b *redisBackend) Close() error {
+	close(b.closed)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for conn := range b.subscriptionConns {
+		_ = conn.Close()
+	}
+
+	var err error
+	for _, conn := range b.idleConns {
+		if closeErr := conn.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	b.idleConns = nil
+
+	return err
+}
+
+func (b *redisBackend) do(ctx context.Context, args ...string) (interface{}, error) {
+	select {
+	case b.slots <- struct{}{}:
+		defer func() { <-b.slots }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	conn, err := b.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := conn.do(ctx, args...)
+	if err != nil {
+		// the connection is in an unknown state, the next command will dial a new one
+		_ = conn.Close()
+		return nil, err
+	}
+
+	b.putConn(conn)
+
+	if replyErr, ok := reply.(respError); ok {
+		return nil, replyErr
+	}
+
+	return reply, nil
+}
+
+// conn returns an idle connection or dials a new one
+func (b *redisBackend) conn(ctx context.Context) (*respConn, error) {
+	b.mu.Lock()
+	if n := len(b.idleConns); n > 0 {
+		conn := b.idleConns[n-1]
+		b.idleConns = b.idleConns[:n-1]
+		b.mu.Unlock()
+
+		return conn, nil
+	}
+	b.mu.Unlock()
+
+	conn, err := dialRESP(ctx, b.address)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to redis: %w", err)
+	}
+
+	return conn, nil
+}
+
+// putConn keeps the connection for the next command, unless the backend was closed in the meantime
+func (b *redisBackend) putConn(conn *respConn) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	select {
+	case <-b.closed:
+		_ = conn.Close()
+	default:
+		b.idleConns = append(b.idleConns, conn)
+	}
+}
+
+func (b *redisBackend) subscribe(ctx context.Context, nodeID string) (*respConn, error) {
+	conn, err := dialRESP(ctx, b.address)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to redis: %w", err)
+	}
+
+	reply, err := conn.do(ctx, "SUBSCRIBE", nodeChannel(nodeID))
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	if replyErr, ok := reply.(respError); ok {
+		_ = conn.Close()
+		return nil, replyErr
+	}
+
+	b.mu.Lock()
+	b.subscriptionConns[conn] = struct{}{}
+	b.mu.Unlock()
+
+	return conn, nil
+}
+
+func (b *redisBackend) closeSubscriptionConn(conn *respConn) {
+	b.mu.Lock()
+	delete(b.subscriptionConns, conn)
+	b.mu.Unlock()
+
+	_ = conn.Close()
+}
+
+func (b *redisBackend) readSubscription(conn *respConn, subscription chan<- Envelope) {
+	for {
+		reply, err := conn.readReply()
+		if err != nil {
+			select {
+			case <-b.closed:
+			default:
+				logrus.Errorf("error reading cluster channel: %s", err.Error())
+			}
+			return
+		}
+
+		items, ok := reply.([]interface{})
+		if !ok || len(items) != 3 || items[0] != "message" {
+			continue
+		}
+
+		payload, ok := items[2].(string)
+		if !ok {
+			continue
+		}
+
+		var envelope Envelope
+		if err = json.Unmarshal([]byte(payload), &envelope); err != nil {
+			logrus.Errorf("error decoding cluster envelope: %s", err.Error())
+			continue
+		}
+
+		subscription <- envelope
+	}
+}
+
+func gameKey(gameID string) string {
+	return redisKeyPrefix + "game:" + gameID
+}
+
+func nodeChannel(nodeID string) string {
+	return redisKeyPrefix + "node:" + nodeID
+}
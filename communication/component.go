@@ -6,6 +6,9 @@ import (
 
 	"github.com/google/uuid"
 	cmap "github.com/orcaman/concurrent-map/v2"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ScruffyPants/talk-to-zombies/tracing"
 )
 
 type Connection interface {
@@ -16,7 +19,8 @@ type Service interface {
 	HandleMessage(ctx context.Context, connectionID string, message Message) error
 	HandleDisconnect(ctx context.Context, connectionID string)
 	NewConnection(connection Connection) (string, error)
-	SendMessageToConnection(connectionID string, message []byte) error
+	RegisterConnection(connectionID string, connection Connection)
+	SendMessageToConnection(ctx context.Context, connectionID string, message []byte) error
 
 	AddListener(listener Listener)
 }
@@ -48,10 +52,24 @@ func (s *service) NewConnection(connection Connection) (string, error) {
 	return connectionID, nil
 }
 
+// RegisterConnection stores a connection under an ID which was already assigned elsewhere,
+// f.x. a connection forwarded from a different node
+func (s *service) RegisterConnection(connectionID string, connection Connection) {
+	s.connectionStore.Set(connectionID, connection)
+}
+
 func (s *service) HandleMessage(ctx context.Context, connectionID string, message Message) error {
+	ctx, span := tracing.Tracer().Start(ctx, "communication.HandleMessage", trace.WithAttributes(
+		tracing.AttributeConnectionID.String(connectionID),
+		tracing.AttributeCommand.String(message.Type),
+	))
+	defer span.End()
+
 	_, ok := s.connectionStore.Get(connectionID)
 	if !ok {
-		return fmt.Errorf("connection not found")
+		err := fmt.Errorf("connection not found")
+		tracing.RecordError(span, err)
+		return err
 	}
 
 	s.BroadcastMessageToAllListeners(ctx, connectionID, message)
@@ -64,13 +82,23 @@ func (s *service) HandleDisconnect(ctx context.Context, connectionID string) {
 	s.BroadcastDisconnectToAllListeners(ctx, connectionID)
 }
 
-func (s *service) SendMessageToConnection(connectionID string, message []byte) error {
+func (s *service) SendMessageToConnection(ctx context.Context, connectionID string, message []byte) error {
+	_, span := tracing.Tracer().Start(ctx, "communication.SendMessageToConnection", trace.WithAttributes(
+		tracing.AttributeConnectionID.String(connectionID),
+	))
+	defer span.End()
+
 	connection, ok := s.connectionStore.Get(connectionID)
 	if !ok {
-		return fmt.Errorf("connection not found")
+		err := fmt.Errorf("connection not found")
+		tracing.RecordError(span, err)
+		return err
 	}
 
-	return connection.SendMessage(message)
+	err := connection.SendMessage(message)
+	tracing.RecordError(span, err)
+
+	return err
 }
 
 func (s *service) AddListener(listener Listener) {
@@ -79,7 +107,12 @@ func (s *service) AddListener(listener Listener) {
 
 func (s *service) BroadcastMessageToAllListeners(ctx context.Context, connectionID string, message Message) {
 	s.listeners.IterCb(func(_ string, listener Listener) {
-		listener.OnMessage(ctx, connectionID, message)
+		listenerCtx, span := tracing.Tracer().Start(ctx, "communication.Listener.OnMessage", trace.WithAttributes(
+			tracing.AttributeListener.String(fmt.Sprintf("%T", listener)),
+		))
+		defer span.End()
+
+		listener.OnMessage(listenerCtx, connectionID, message)
 	})
 }
 
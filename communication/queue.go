@@ -0,0 +1,188 @@
+package communication
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+)
+
+var (
+	ErrConnectionClosed = fmt.Errorf("connection is closed")
+	ErrSlowConsumer     = fmt.Errorf("connection is too slow to keep up")
+)
+
+// outboundMetrics are published on /debug/vars
+var outboundMetrics = expvar.NewMap("outbound")
+
+type OverflowPolicy string
+
+const (
+	// OverflowDrop drops queued messages the client can do without to make room
+	OverflowDrop OverflowPolicy = "drop"
+	// OverflowDisconnect drops like OverflowDrop but closes the connection after too many overflows in a row
+	OverflowDisconnect OverflowPolicy = "disconnect"
+)
+
+func ParseOverflowPolicy(policy string) (OverflowPolicy, error) {
+	switch OverflowPolicy(policy) {
+	case "", OverflowDrop:
+		return OverflowDrop, nil
+	case OverflowDisconnect:
+		return OverflowDisconnect, nil
+	default:
+		return "", fmt.Errorf("unsupported overflow policy %s", policy)
+	}
+}
+
+type QueueSettings struct {
+	// Size is the number of messages waiting for a connection before it overflows
+	Size   int
+	Policy OverflowPolicy
+	// MaxOverflows is the number of overflows in a row after which OverflowDisconnect closes
+	// the connection, the count starts over once the client catches up with the queue
+	MaxOverflows int
+	// Droppable reports whether a message may be dropped on overflow, the oldest droppable
+	// message goes first and nothing is dropped if nil. A connection whose queue is full of
+	// messages which can't be dropped is disconnected whatever the policy
+	Droppable func(message []byte) bool
+}
+
+// Closer is implemented by connections the disconnect policy can close
+type Closer interface {
+	Close() error
+}
+
+// queuedConnection holds the messages for a connection in a bounded queue and sends them
+// from a goroutine of its own, so a slow client holds up nobody but itself
+type queuedConnection struct {
+	connection Connection
+	settings   QueueSettings
+
+	mu        sync.Mutex
+	ready     *sync.Cond
+	queue     [][]byte
+	overflows int
+	stopped   bool
+}
+
+var _ Connection = (*queuedConnection)(nil)
+
+func NewQueuedConnection(connection Connection, settings QueueSettings) *queuedConnection {
+	if settings.Size <= 0 {
+		settings.Size = 1
+	}
+
+	c := &queuedConnection{
+		connection: connection,
+		settings:   settings,
+	}
+	c.ready = sync.NewCond(&c.mu)
+
+	go c.run()
+
+	return c
+}
+
+func (c *queuedConnection) SendMessage(message []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.stopped {
+		return ErrConnectionClosed
+	}
+
+	if len(c.queue) >= c.settings.Size {
+		outboundMetrics.Add("overflows", 1)
+		c.overflows++
+
+		if c.settings.Policy == OverflowDisconnect && c.overflows >= c.settings.MaxOverflows {
+			c.disconnectLocked()
+			return ErrSlowConsumer
+		}
+
+		queue, ok := c.makeRoomLocked(message)
+		if !ok {
+			// Queueing the message anyway would let the queue grow without bounds
+			c.disconnectLocked()
+			return ErrSlowConsumer
+		}
+
+		if !queue {
+			outboundMetrics.Add("dropped", 1)
+			return nil
+		}
+	}
+
+	c.queue = append(c.queue, message)
+	c.ready.Signal()
+
+	return nil
+}
+
+// makeRoomLocked drops the oldest droppable queued message and reports whether the new message
+// should be queued, or if nothing can be dropped but the new message itself, that it is dropped;
+// ok is false if neither can be dropped
+func (c *queuedConnection) makeRoomLocked(message []byte) (queue bool, ok bool) {
+	if c.settings.Droppable == nil {
+		return false, false
+	}
+
+	for j, queued := range c.queue {
+		if c.settings.Droppable(queued) {
+			c.queue = append(c.queue[:j], c.queue[j+1:]...)
+			outboundMetrics.Add("dropped", 1)
+			return true, true
+		}
+	}
+
+	return false, c.settings.Droppable(message)
+}
+
+// disconnectLocked stops the queue and closes the connection of a client which can't keep up
+func (c *queuedConnection) disconnectLocked() {
+	outboundMetrics.Add("disconnects", 1)
+	c.stopLocked()
+
+	if closer, ok := c.connection.(Closer); ok {
+		go closer.Close()
+	}
+}
+
+// Stop discards the queued messages and ends the sending goroutine, f.x. once the client disconnected
+func (c *queuedConnection) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.stopLocked()
+}
+
+func (c *queuedConnection) stopLocked() {
+	c.stopped = true
+	c.queue = nil
+	c.ready.Signal()
+}
+
+func (c *queuedConnection) run() {
+	for {
+		c.mu.Lock()
+		for len(c.queue) == 0 && !c.stopped {
+			c.ready.Wait()
+		}
+
+		if c.stopped {
+			c.mu.Unlock()
+			return
+		}
+
+		message := c.queue[0]
+		c.queue = c.queue[1:]
+		if len(c.queue) == 0 {
+			c.overflows = 0
+		}
+		c.mu.Unlock()
+
+		if err := c.connection.SendMessage(message); err != nil {
+			outboundMetrics.Add("errors", 1)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package functional_tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ScruffyPants/talk-to-zombies/game"
+	"github.com/ScruffyPants/talk-to-zombies/player"
+)
+
+func TestChat(t *testing.T) {
+	communicationService, _ := newTestGameNode(t, game.Settings{
+		ZombieCoordinateUpdateInterval: time.Hour,
+		Modes:                          game.ModeSettings{CoopWaves: 1, CoopWaveSize: 1},
+		Chat:                           game.ChatSettings{MaxLength: 10, RateLimit: 3, RatePeriod: time.Hour},
+	},
+		game.ZombieType{Name: "Tank", Movement: game.MovementCharge, Points: 1},
+	)
+
+	alice := &recordingConnection{messages: make(chan string, 16)}
+	aliceConnectionID, err := communicationService.NewConnection(alice)
+	require.NoError(t, err)
+
+	sendTestClusterMessage(t, communicationService, aliceConnectionID, "START alice coop")
+	splitMessage := strings.Split(readTestClusterMessage(t, alice), " ")
+	require.Len(t, splitMessage, 3)
+	assert.Equal(t, "WAVE 1", readTestClusterMessage(t, alice))
+
+	bob := &recordingConnection{messages: make(chan string, 16)}
+	bobConnectionID, err := communicationService.NewConnection(bob)
+	require.NoError(t, err)
+	joinTestClusterGame(t, communicationService, bobConnectionID, bob, splitMessage[1], "bob", alice)
+
+	sendTestClusterMessage(t, communicationService, aliceConnectionID, "SAY hi bob")
+	assert.Equal(t, "SAY alice hi bob", readTestClusterMessage(t, alice))
+	assert.Equal(t, "SAY alice hi bob", readTestClusterMessage(t, bob))
+
+	sendTestClusterMessage(t, communicationService, bobConnectionID, "TEAM left")
+	assert.Equal(t, "TEAM bob left", readTestClusterMessage(t, alice))
+	assert.Equal(t, "TEAM bob left", readTestClusterMessage(t, bob))
+
+	sendTestClusterMessage(t, communicationService, bobConnectionID, "SAY this is too long")
+	assert.Equal(t, "chat message is longer than 10 characters", readTestClusterMessage(t, bob))
+
+	sendTestClusterMessage(t, communicationService, aliceConnectionID, "MUTE bob")
+	assert.Equal(t, "MUTED bob", readTestClusterMessage(t, alice))
+
+	// Alice muted bob and doesn't get his messages, his third one reaches the rate limit
+	sendTestClusterMessage(t, communicationService, bobConnectionID, "SAY quiet")
+	assert.Equal(t, "SAY bob quiet", readTestClusterMessage(t, bob))
+	sendTestClusterMessage(t, communicationService, bobConnectionID, "SAY still")
+	assert.Equal(t, "SAY bob still", readTestClusterMessage(t, bob))
+	assert.Empty(t, alice.messages)
+
+	sendTestClusterMessage(t, communicationService, bobConnectionID, "SAY more")
+	assert.Equal(t, "slow down, chat is limited to 3 messages per 1h0m0s", readTestClusterMessage(t, bob))
+
+	sendTestClusterMessage(t, communicationService, aliceConnectionID, "UNMUTE bob")
+	assert.Equal(t, "UNMUTED bob", readTestClusterMessage(t, alice))
+}
+
+func TestChatWithoutTeams(t *testing.T) {
+	communicationService := newTestModeNode(t)
+	connection, connectionID, _ := startTestModeGame(t, communicationService, game.ModeFFA)
+
+	sendTestClusterMessage(t, communicationService, connectionID, "TEAM hello")
+	assert.Equal(t, game.ErrNoTeams.Error(), readTestClusterMessage(t, connection))
+}
+
+func TestTeamChatOnlyReachesTeammates(t *testing.T) {
+	communicationService, _ := newTestGameNode(t, game.Settings{
+		ZombieCoordinateUpdateInterval: time.Hour,
+		WallHealth:                     1,
+	},
+		game.ZombieType{Name: "Runner", MoveInterval: 60 * time.Millisecond, Movement: game.MovementCharge},
+	)
+
+	connections := map[string]*recordingConnection{}
+	connectionIDs := map[string]string{}
+	for _, username := range []string{"alice", "bob", "carol", "dave"} {
+		connections[username] = &recordingConnection{messages: make(chan string, 1024)}
+		connectionID, err := communicationService.NewConnection(connections[username])
+		require.NoError(t, err)
+		connectionIDs[username] = connectionID
+	}
+
+	sendTestClusterMessage(t, communicationService, connectionIDs["alice"], "START alice versus")
+	splitMessage := strings.Split(readTestClusterMessage(t, connections["alice"]), " ")
+	require.Len(t, splitMessage, 3)
+	joinTestClusterGame(t, communicationService, connectionIDs["bob"], connections["bob"], splitMessage[1], "bob", connections["alice"])
+
+	// The zombies of carol and dave start walking a second later, so alice and bob are eliminated first
+	time.Sleep(time.Second)
+	joinTestClusterGame(t, communicationService, connectionIDs["carol"], connections["carol"], splitMessage[1], "carol",
+		connections["alice"], connections["bob"])
+	joinTestClusterGame(t, communicationService, connectionIDs["dave"], connections["dave"], splitMessage[1], "dave",
+		connections["alice"], connections["bob"], connections["carol"])
+
+	sendTestClusterMessage(t, communicationService, connectionIDs["carol"], "TEAM hello")
+	assert.Equal(t, game.ErrNoTeams.Error(), readTestClusterMessageSkippingDeltas(t, connections["carol"]))
+
+	for eliminated := 0; eliminated < 2; {
+		if strings.HasPrefix(readTestClusterMessageSkippingDeltas(t, connections["bob"]), "ELIMINATED ") {
+			eliminated++
+		}
+	}
+
+	sendTestClusterMessage(t, communicationService, connectionIDs["alice"], "TEAM gg")
+	for {
+		if message := readTestClusterMessageSkippingDeltas(t, connections["bob"]); message == "TEAM alice gg" {
+			break
+		}
+	}
+
+	// carol and dave play on until one of them wins, without hearing from the spectators
+	for {
+		message := readTestClusterMessageSkippingDeltas(t, connections["carol"])
+		assert.NotEqual(t, "TEAM alice gg", message)
+
+		if strings.HasPrefix(message, "GAMEOVER ") {
+			break
+		}
+	}
+}
+
+func TestChatFilterMatchesWholeWords(t *testing.T) {
+	denyListPath := filepath.Join(t.TempDir(), "denylist.txt")
+	require.NoError(t, os.WriteFile(denyListPath, []byte("ass\nkill yourself\n"), 0600))
+
+	chatFilter, err := game.NewChatFilterFromFile(denyListPath)
+	require.NoError(t, err)
+
+	for _, text := range []string{"you ass", "what an @$$!", "A.S.S", "go KILL   yourself!"} {
+		assert.False(t, chatFilter.Allowed(text), text)
+	}
+
+	// Only usernames are matched across word boundaries
+	for _, text := range []string{"as sassy as ever", "pass the grenade", "class-act", "kill your zombie", "yourself kill"} {
+		assert.True(t, chatFilter.Allowed(text), text)
+	}
+
+	usernameFilter, err := player.NewDenyListFilterFromFile(denyListPath)
+	require.NoError(t, err)
+	assert.False(t, usernameFilter.Allowed("sassy"))
+}
@@ -0,0 +1,163 @@
+package functional_tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ScruffyPants/talk-to-zombies/cluster"
+	"github.com/ScruffyPants/talk-to-zombies/communication"
+	"github.com/ScruffyPants/talk-to-zombies/game"
+	"github.com/ScruffyPants/talk-to-zombies/player"
+)
+
+type recordingConnection struct {
+	messages chan string
+}
+
+func (c *recordingConnection) SendMessage(message []byte) error {
+	c.messages <- string(message)
+	return nil
+}
+
+func TestClusterJoinOnDifferentNode(t *testing.T) {
+	backend := newTestMemoryBackend(t)
+
+	firstNode, _ := newTestClusterNode(t, backend, nil)
+	secondNode, _ := newTestClusterNode(t, backend, nil)
+
+	hostConnection := &recordingConnection{messages: make(chan string, 16)}
+	hostConnectionID, err := firstNode.NewConnection(hostConnection)
+	require.NoError(t, err)
+
+	sendTestClusterMessage(t, firstNode, hostConnectionID, "START alice")
+	splitMessage := strings.Split(readTestClusterMessage(t, hostConnection), " ")
+	require.Len(t, splitMessage, 2)
+	assert.Equal(t, "GAME", splitMessage[0])
+
+	joinedConnection := &recordingConnection{messages: make(chan string, 16)}
+	joinedConnectionID, err := secondNode.NewConnection(joinedConnection)
+	require.NoError(t, err)
+
+	joinTestClusterGame(t, secondNode, joinedConnectionID, joinedConnection, splitMessage[1], "bob", hostConnection)
+
+	sendTestClusterMessage(t, secondNode, joinedConnectionID, "SHOOT 500 500")
+
+	assert.Equal(t, "BOOM bob 0", readTestClusterMessage(t, joinedConnection))
+}
+
+func TestClusterJoinOnGoneNode(t *testing.T) {
+	backend := newTestMemoryBackend(t)
+
+	// The game is still claimed by a node which is gone
+	claimed, err := backend.ClaimGame(context.Background(), "ghost", uuid.NewString())
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	communicationService, _ := newTestClusterNode(t, backend, nil)
+
+	connection := &recordingConnection{messages: make(chan string, 16)}
+	connectionID, err := communicationService.NewConnection(connection)
+	require.NoError(t, err)
+
+	sendTestClusterMessage(t, communicationService, connectionID, "JOIN ghost bob")
+	assert.Equal(t, "error looking up game: "+cluster.ErrNodeNotFound.Error(), readTestClusterMessage(t, connection))
+}
+
+// newTestMemoryBackend is closed once the test is done
+func newTestMemoryBackend(t *testing.T) cluster.Backend {
+	backend := cluster.NewMemoryBackend()
+	t.Cleanup(func() {
+		require.NoError(t, backend.Close())
+	})
+
+	return backend
+}
+
+// newTestGameNode runs a single node with its own backend, playing with the zombie types if any are given
+func newTestGameNode(t *testing.T, settings game.Settings, types ...game.ZombieType) (communication.Service, game.Component) {
+	if len(types) > 0 {
+		zombieTypes, err := game.NewZombieTypeRegistry(types)
+		require.NoError(t, err)
+
+		settings.ZombieTypes = zombieTypes
+	}
+
+	return newTestClusterNodeWithSettings(t, newTestMemoryBackend(t), nil, settings)
+}
+
+func newTestClusterNode(t *testing.T, backend cluster.Backend, snapshotStore game.SnapshotStore) (communication.Service, game.Component) {
+	return newTestClusterNodeWithSettings(t, backend, snapshotStore, game.Settings{ZombieCoordinateUpdateInterval: time.Hour})
+}
+
+func newTestClusterNodeWithSettings(
+	t *testing.T,
+	backend cluster.Backend,
+	snapshotStore game.SnapshotStore,
+	gameSettings game.Settings) (communication.Service, game.Component) {
+	communicationService := communication.NewCommunicationService()
+
+	node, err := cluster.NewClusterNode(cluster.Settings{NodeID: uuid.NewString()}, backend, communicationService)
+	require.NoError(t, err)
+
+	playerComponent, err := player.NewPlayerComponent(player.Settings{MinUsernameLength: 2, MaxUsernameLength: 32}, nil)
+	require.NoError(t, err)
+
+	gameComponent, err := game.NewGameComponent(
+		playerComponent,
+		communicationService,
+		node,
+		snapshotStore,
+		gameSettings,
+	)
+	require.NoError(t, err)
+
+	node.SetLocalListener(gameComponent)
+	communicationService.AddListener(node)
+
+	return communicationService, gameComponent
+}
+
+func sendTestClusterMessage(t *testing.T, communicationService communication.Service, connectionID string, text string) {
+	splitText := strings.Split(text, " ")
+
+	require.NoError(t, communicationService.HandleMessage(context.Background(), connectionID, communication.Message{
+		Type:      splitText[0],
+		Arguments: splitText[1:],
+	}))
+}
+
+// joinTestClusterGame joins the game and reads the welcome and the game's state, the players
+// already in the game read who joined
+func joinTestClusterGame(
+	t *testing.T,
+	communicationService communication.Service,
+	connectionID string,
+	connection *recordingConnection,
+	gameID string,
+	username string,
+	others ...*recordingConnection) {
+	sendTestClusterMessage(t, communicationService, connectionID, "JOIN "+gameID+" "+username)
+	assert.Equal(t, "WELCOME "+gameID+" "+username, readTestClusterMessageSkippingDeltas(t, connection))
+	require.True(t, strings.HasPrefix(readTestClusterMessage(t, connection), "SYNC "))
+
+	for _, other := range others {
+		assert.Equal(t, "JOINED "+username, readTestClusterMessageSkippingDeltas(t, other))
+		require.True(t, strings.HasPrefix(readTestClusterMessageSkippingDeltas(t, other), "ROSTER "))
+	}
+}
+
+func readTestClusterMessage(t *testing.T, connection *recordingConnection) string {
+	select {
+	case message := <-connection.messages:
+		return message
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for message")
+		return ""
+	}
+}
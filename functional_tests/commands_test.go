@@ -0,0 +1,119 @@
+package functional_tests
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ScruffyPants/talk-to-zombies/game"
+)
+
+func TestCommandRegistry(t *testing.T) {
+	communicationService, _ := newTestGameNode(t, game.Settings{
+		ZombieCoordinateUpdateInterval: time.Hour,
+	},
+		game.ZombieType{Name: "Tank", HitPoints: 2, Movement: game.MovementCharge, Points: 1},
+	)
+
+	connection := &recordingConnection{messages: make(chan string, 16)}
+	connectionID, err := communicationService.NewConnection(connection)
+	require.NoError(t, err)
+
+	// Outside of a game only the lobby commands are listed
+	sendTestClusterMessage(t, communicationService, connectionID, "HELP")
+	assert.Equal(t, "COMMANDS BEST HELP JOIN START", readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "help fire")
+	assert.Equal(t, "HELP SHOOT {x} {y} -- shoots at a square with the selected weapon (also FIRE)", readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "HELP FOO")
+	assert.Equal(t, "command FOO is not supported", readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "SHOOT 0 0")
+	assert.Equal(t, "must be in a game", readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "START")
+	assert.Equal(t, "start command requires at least one argument: START {player} [rule=value ...]", readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "START alice")
+	require.True(t, strings.HasPrefix(readTestClusterMessage(t, connection), "GAME "))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "JOIN game bob")
+	assert.Equal(t, "already in a game", readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "SHOOT 0")
+	assert.Equal(t, "shoot command requires two arguments: SHOOT {x} {y}", readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "SHOOT 0 y")
+	assert.Equal(t, "y must be an integer", readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "SCAN now")
+	assert.Equal(t, "scan command takes no arguments", readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "BEST alice bob")
+	assert.Equal(t, "best command takes at most one argument: BEST [username]", readTestClusterMessage(t, connection))
+
+	// Aliases run the same command
+	sendTestClusterMessage(t, communicationService, connectionID, "fire 0 0")
+	assert.Equal(t, "BOOM alice 0 Tank:1", readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "WHO")
+	assert.Equal(t, "ROSTER alice", readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "HELP")
+	assert.Equal(t, "COMMANDS BEST END HELP KICK MUTE PAUSE PLAYERS RESUME SAY SCAN SHOOT SYNC TEAM TRANSFER UNMUTE WEAPON",
+		readTestClusterMessage(t, connection))
+}
+
+func TestCommandRateLimit(t *testing.T) {
+	communicationService, _ := newTestGameNode(t, game.Settings{
+		ZombieCoordinateUpdateInterval: time.Hour,
+		Commands:                       game.CommandSettings{RateLimit: 2, RatePeriod: time.Hour},
+	})
+
+	connection := &recordingConnection{messages: make(chan string, 16)}
+	connectionID, err := communicationService.NewConnection(connection)
+	require.NoError(t, err)
+
+	for j := 0; j < 2; j++ {
+		sendTestClusterMessage(t, communicationService, connectionID, "HELP")
+		assert.True(t, strings.HasPrefix(readTestClusterMessage(t, connection), "COMMANDS "))
+	}
+
+	sendTestClusterMessage(t, communicationService, connectionID, "HELP")
+	assert.Equal(t, "slow down, commands are limited to 2 per 1h0m0s", readTestClusterMessage(t, connection))
+
+	// Another connection has a limit of its own
+	other := &recordingConnection{messages: make(chan string, 16)}
+	otherConnectionID, err := communicationService.NewConnection(other)
+	require.NoError(t, err)
+
+	sendTestClusterMessage(t, communicationService, otherConnectionID, "HELP")
+	assert.True(t, strings.HasPrefix(readTestClusterMessage(t, other), "COMMANDS "))
+}
+
+func TestChatCountsTowardsCommandRateLimit(t *testing.T) {
+	communicationService, _ := newTestGameNode(t, game.Settings{
+		ZombieCoordinateUpdateInterval: time.Hour,
+		Commands:                       game.CommandSettings{RateLimit: 3, RatePeriod: time.Hour},
+	})
+
+	connection := &recordingConnection{messages: make(chan string, 16)}
+	connectionID, err := communicationService.NewConnection(connection)
+	require.NoError(t, err)
+
+	sendTestClusterMessage(t, communicationService, connectionID, "START alice")
+	assert.True(t, strings.HasPrefix(readTestClusterMessage(t, connection), "GAME "))
+
+	// Chat messages are commands as well and use up the same window
+	for _, text := range []string{"hi", "again"} {
+		sendTestClusterMessage(t, communicationService, connectionID, "SAY "+text)
+		assert.Equal(t, "SAY alice "+text, readTestClusterMessageSkippingDeltas(t, connection))
+	}
+
+	sendTestClusterMessage(t, communicationService, connectionID, "HELP")
+	assert.Equal(t, "slow down, commands are limited to 3 per 1h0m0s", readTestClusterMessageSkippingDeltas(t, connection))
+}
@@ -0,0 +1,128 @@
+package functional_tests
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ScruffyPants/talk-to-zombies/communication"
+	"github.com/ScruffyPants/talk-to-zombies/game"
+)
+
+func newTestEndlessNode(t *testing.T, zombieType game.ZombieType, records game.RunStore) communication.Service {
+	communicationService, _ := newTestGameNode(t, game.Settings{
+		ZombieCoordinateUpdateInterval: time.Hour,
+		WallHealth:                     1,
+		Endless: game.EndlessSettings{
+			FirstWaveSize:  1,
+			WaveGrowth:     1,
+			SpeedUp:        0.5,
+			ToughnessEvery: 1,
+			Records:        records,
+		},
+	}, zombieType)
+
+	return communicationService
+}
+
+func TestEndlessWavesGetHarder(t *testing.T) {
+	communicationService := newTestEndlessNode(t, game.ZombieType{Name: "Tank", Movement: game.MovementCharge, Points: 1}, nil)
+	connection, connectionID, _ := startTestModeGame(t, communicationService, game.ModeEndless)
+
+	assert.Equal(t, "WAVE 1", readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "SHOOT 0 0")
+	assert.Equal(t, "BOOM alice 1 Tank", readTestClusterMessage(t, connection))
+	assert.Equal(t, "WAVE 2", readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "WEAPON shotgun")
+	assert.Equal(t, "WEAPON shotgun", readTestClusterMessage(t, connection))
+
+	// Two zombies with an extra hit point each
+	sendTestClusterMessage(t, communicationService, connectionID, "SHOOT 0 0")
+	assert.Equal(t, "BOOM alice 0 Tank:1 Tank_2:1", readTestClusterMessage(t, connection))
+}
+
+func TestEndlessRunRecords(t *testing.T) {
+	records := game.NewMemoryRunStore()
+	communicationService := newTestEndlessNode(t, game.ZombieType{
+		Name: "Runner", MoveInterval: 5 * time.Millisecond, Movement: game.MovementCharge,
+	}, records)
+	connection, connectionID, _ := startTestModeGame(t, communicationService, game.ModeEndless)
+
+	var results []string
+	for len(results) < 4 {
+		if message := readTestClusterMessage(t, connection); !strings.HasPrefix(message, "DELTA ") {
+			results = append(results, message)
+		}
+	}
+	assert.Equal(t, []string{"WAVE 1", "WALL 0", "RECORD alice 1 0", "GAMEOVER SURVIVED 1 0"}, results)
+
+	run, ok, err := records.Best("alice")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, 1, run.Wave)
+
+	sendTestClusterMessage(t, communicationService, connectionID, "BEST alice")
+	assert.Equal(t, "BEST alice 1 0", readTestClusterMessage(t, connection))
+}
+
+func TestEndlessRunLeavesOutPauses(t *testing.T) {
+	communicationService := newTestEndlessNode(t, game.ZombieType{
+		Name: "Runner", MoveInterval: 20 * time.Millisecond, Movement: game.MovementCharge,
+	}, nil)
+	connection, connectionID, _ := startTestModeGame(t, communicationService, game.ModeEndless)
+
+	sendTestClusterMessage(t, communicationService, connectionID, "PAUSE")
+	assert.Equal(t, "WAVE 1", readTestClusterMessage(t, connection))
+	assert.Equal(t, "PAUSED alice", readTestClusterMessageSkippingDeltas(t, connection))
+
+	time.Sleep(1500 * time.Millisecond)
+
+	sendTestClusterMessage(t, communicationService, connectionID, "RESUME")
+	assert.Equal(t, "RESUMED alice", readTestClusterMessageSkippingDeltas(t, connection))
+
+	// The zombie walks for about 600ms, the pause would round it up to 2 seconds
+	assert.Equal(t, "WALL 0", readTestClusterMessageSkippingDeltas(t, connection))
+	assert.Equal(t, "RECORD alice 1 1", readTestClusterMessageSkippingDeltas(t, connection))
+	assert.Equal(t, "GAMEOVER SURVIVED 1 1", readTestClusterMessageSkippingDeltas(t, connection))
+}
+
+func TestFileRunStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.json")
+
+	records, err := game.NewFileRunStore(path)
+	require.NoError(t, err)
+
+	for _, run := range []game.Run{
+		{Username: "alice", Wave: 2, Survived: time.Minute},
+		{Username: "alice", Wave: 1, Survived: time.Hour},
+		{Username: "bob", Wave: 1, Survived: time.Second},
+	} {
+		_, err = records.Record(run)
+		require.NoError(t, err)
+	}
+
+	// Closing waits for the records to be written
+	require.NoError(t, records.Close())
+
+	reloaded, err := game.NewFileRunStore(path)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, reloaded.Close())
+	})
+
+	run, ok, err := reloaded.Best("alice")
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.Equal(t, 2, run.Wave)
+	assert.Equal(t, time.Minute, run.Survived)
+
+	_, ok, err = reloaded.Best("bob")
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
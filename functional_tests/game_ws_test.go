@@ -52,7 +52,7 @@ func testSingleWebsocketGame(t *testing.T) {
 		require.NoError(t, wsConnection.Close(websocket.StatusNormalClosure, "disconnect"))
 	}()
 
-	name := uuid.NewString()
+	name := newTestUsername()
 
 	gameID := testStartGame(t, wsConnection, name)
 
@@ -60,13 +60,18 @@ func testSingleWebsocketGame(t *testing.T) {
 
 	testMissedShots(t, wsConnection, name)
 
-	xCord, yCord := testWalkMessage(t, wsConnection)
+	xCord, yCord := testDeltaMessage(t, wsConnection)
 
 	testHitShot(t, wsConnection, name, xCord, yCord)
 }
 
+// newTestUsername returns a unique username within the allowed username length
+func newTestUsername() string {
+	return "player-" + strings.ReplaceAll(uuid.NewString(), "-", "")[:12]
+}
+
 func makeWSConnection(t *testing.T) *websocket.Conn {
-	c, _, err := websocket.Dial(context.Background(), fmt.Sprintf("ws://%s", testWSAddress), nil)
+	c, _, err := websocket.Dial(context.Background(), fmt.Sprintf("ws://%s/ws", testWSAddress), nil)
 	require.NoError(t, err)
 
 	return c
@@ -99,9 +104,11 @@ func testJoinGame(t *testing.T, gameID string) {
 				require.NoError(t, wsConnection.Close(websocket.StatusNormalClosure, "disconnect"))
 			}()
 
-			name := uuid.NewString()
+			name := newTestUsername()
 
 			testWriteWSMessageWithTimeout(t, wsConnection, fmt.Sprintf("JOIN %s %s", gameID, name))
+			assert.Equal(t, fmt.Sprintf("WELCOME %s %s", gameID, name), testReadWSMessageWithTimeout(t, wsConnection))
+			assert.True(t, strings.HasPrefix(testReadWSMessageWithTimeout(t, wsConnection), "SYNC "))
 
 			testMissedShots(t, wsConnection, name)
 		}()
@@ -128,7 +135,7 @@ func testMissedShots(t *testing.T, wsConnection *websocket.Conn, name string) {
 					t.Fatal("failed to get missed shot response")
 				}
 
-				// Dealing with possibility of getting WALK (or different) message
+				// Dealing with possibility of getting DELTA (or different) message
 				// before getting response to BOOM message, however assuming the next
 				// message will be response to the BOOM message
 				gotDifferentMessage = true
@@ -147,34 +154,58 @@ func testMissedShots(t *testing.T, wsConnection *websocket.Conn, name string) {
 	}
 }
 
-func testWalkMessage(t *testing.T, wsConnection *websocket.Conn) (int, int) {
-	message := testReadWSMessageWithTimeout(t, wsConnection)
+func testDeltaMessage(t *testing.T, wsConnection *websocket.Conn) (int, int) {
+	return parseTestDelta(t, testReadWSMessageWithTimeout(t, wsConnection))
+}
 
+// parseTestDelta returns the position of the only zombie in a DELTA frame
+func parseTestDelta(t *testing.T, message string) (int, int) {
 	splitMessage := strings.Split(message, " ")
-	require.Len(t, splitMessage, 4)
+	require.Len(t, splitMessage, 5)
+
+	assert.Equal(t, "DELTA", splitMessage[0])
+
+	require.True(t, strings.HasPrefix(splitMessage[2], "zombies="))
 
-	assert.Equal(t, "WALK", splitMessage[0])
+	position := strings.Split(strings.TrimPrefix(splitMessage[2], "zombies="), ":")
+	require.Len(t, position, 3)
 
-	xCord, err := strconv.Atoi(splitMessage[2])
+	xCord, err := strconv.Atoi(position[1])
 	require.NoError(t, err)
 
-	yCord, err := strconv.Atoi(splitMessage[3])
+	yCord, err := strconv.Atoi(position[2])
 	require.NoError(t, err)
 
 	return xCord, yCord
 }
 
 func testHitShot(t *testing.T, wsConnection *websocket.Conn, name string, xCord int, yCord int) {
-	testWriteWSMessageWithTimeout(t, wsConnection, fmt.Sprintf("SHOOT %d %d", xCord, yCord))
+	// Tougher zombies survive a few shots and keep walking, so keep shooting at
+	// the latest known position until the zombie is killed
+	for {
+		testWriteWSMessageWithTimeout(t, wsConnection, fmt.Sprintf("SHOOT %d %d", xCord, yCord))
 
-	message := testReadWSMessageWithTimeout(t, wsConnection)
+		message := testReadWSMessageWithTimeout(t, wsConnection)
 
-	splitMessage := strings.Split(message, " ")
-	require.Len(t, splitMessage, 4)
+		splitMessage := strings.Split(message, " ")
+
+		if splitMessage[0] == "DELTA" {
+			xCord, yCord = parseTestDelta(t, message)
+
+			// The shot went to the old position, its response is read below
+			message = testReadWSMessageWithTimeout(t, wsConnection)
+			splitMessage = strings.Split(message, " ")
+		}
 
-	assert.Equal(t, "BOOM", splitMessage[0])
-	assert.Equal(t, name, splitMessage[1])
-	assert.Equal(t, "1", splitMessage[2])
+		require.Equal(t, "BOOM", splitMessage[0])
+		assert.Equal(t, name, splitMessage[1])
+
+		if len(splitMessage) == 4 && splitMessage[2] != "0" {
+			return
+		}
+
+		time.Sleep(timeoutBetweenShootCommands)
+	}
 }
 
 func testWriteWSMessageWithTimeout(t *testing.T, wsConnection *websocket.Conn, message string) {
@@ -185,12 +216,22 @@ func testWriteWSMessageWithTimeout(t *testing.T, wsConnection *websocket.Conn, m
 	require.NoError(t, err)
 }
 
+// testReadWSMessageWithTimeout skips the presence messages of the other players coming and going
 func testReadWSMessageWithTimeout(t *testing.T, wsConnection *websocket.Conn) string {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*3)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
 
-	_, messageBytes, err := wsConnection.Read(ctx)
-	require.NoError(t, err)
+	for {
+		_, messageBytes, err := wsConnection.Read(ctx)
+		require.NoError(t, err)
+
+		if message := string(messageBytes); !isPresenceMessage(message) {
+			return message
+		}
+	}
+}
 
-	return string(messageBytes)
+func isPresenceMessage(message string) bool {
+	command, _, _ := strings.Cut(message, " ")
+	return command == "JOINED" || command == "LEFT" || command == "ROSTER"
 }
@@ -0,0 +1,112 @@
+package functional_tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ScruffyPants/talk-to-zombies/game"
+)
+
+// readTestClusterMessageSkippingDeltas returns the next message which isn't a zombie move
+func readTestClusterMessageSkippingDeltas(t *testing.T, connection *recordingConnection) string {
+	for {
+		if message := readTestClusterMessage(t, connection); !strings.HasPrefix(message, "DELTA ") {
+			return message
+		}
+	}
+}
+
+func TestHostControls(t *testing.T) {
+	communicationService, _ := newTestGameNode(t, game.Settings{
+		ZombieCoordinateUpdateInterval: time.Hour,
+		WallHealth:                     20,
+	},
+		game.ZombieType{Name: "Runner", MoveInterval: 20 * time.Millisecond, Movement: game.MovementCharge},
+	)
+
+	alice := &recordingConnection{messages: make(chan string, 1024)}
+	aliceConnectionID, err := communicationService.NewConnection(alice)
+	require.NoError(t, err)
+
+	sendTestClusterMessage(t, communicationService, aliceConnectionID, "START alice")
+	splitMessage := strings.Split(readTestClusterMessage(t, alice), " ")
+	require.Len(t, splitMessage, 2)
+
+	bob := &recordingConnection{messages: make(chan string, 1024)}
+	bobConnectionID, err := communicationService.NewConnection(bob)
+	require.NoError(t, err)
+	joinTestClusterGame(t, communicationService, bobConnectionID, bob, splitMessage[1], "bob", alice)
+
+	sendTestClusterMessage(t, communicationService, bobConnectionID, "PAUSE")
+	assert.Equal(t, game.ErrNotHost.Error(), readTestClusterMessageSkippingDeltas(t, bob))
+
+	sendTestClusterMessage(t, communicationService, aliceConnectionID, "PAUSE")
+	assert.Equal(t, "PAUSED alice", readTestClusterMessageSkippingDeltas(t, alice))
+
+	// Nothing moves while the game is paused
+	time.Sleep(100 * time.Millisecond)
+	for len(alice.messages) > 0 {
+		<-alice.messages
+	}
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, alice.messages)
+
+	sendTestClusterMessage(t, communicationService, aliceConnectionID, "SHOOT 0 0")
+	assert.Equal(t, game.ErrGamePaused.Error(), readTestClusterMessage(t, alice))
+
+	sendTestClusterMessage(t, communicationService, aliceConnectionID, "RESUME")
+	assert.Equal(t, "RESUMED alice", readTestClusterMessage(t, alice))
+	assert.True(t, strings.HasPrefix(readTestClusterMessage(t, alice), "DELTA "))
+
+	sendTestClusterMessage(t, communicationService, aliceConnectionID, "TRANSFER bob")
+	assert.Equal(t, "HOST bob", readTestClusterMessageSkippingDeltas(t, alice))
+
+	sendTestClusterMessage(t, communicationService, bobConnectionID, "KICK alice")
+	assert.Equal(t, "KICKED alice", readTestClusterMessageSkippingDeltas(t, alice))
+
+	sendTestClusterMessage(t, communicationService, aliceConnectionID, "JOIN "+splitMessage[1]+" alice")
+	assert.Equal(t, game.ErrPlayerKicked.Error(), readTestClusterMessageSkippingDeltas(t, alice))
+
+	sendTestClusterMessage(t, communicationService, aliceConnectionID, "JOIN "+splitMessage[1]+" ALICE")
+	assert.Equal(t, game.ErrPlayerKicked.Error(), readTestClusterMessageSkippingDeltas(t, alice))
+
+	for {
+		if message := readTestClusterMessageSkippingDeltas(t, bob); message == "KICKED alice" {
+			break
+		}
+	}
+	assert.Equal(t, "LEFT alice", readTestClusterMessageSkippingDeltas(t, bob))
+	assert.Equal(t, "ROSTER bob", readTestClusterMessageSkippingDeltas(t, bob))
+
+	sendTestClusterMessage(t, communicationService, bobConnectionID, "END")
+	assert.Equal(t, "GAMEOVER ENDED bob", readTestClusterMessageSkippingDeltas(t, bob))
+}
+
+func TestHostPassesOnDisconnect(t *testing.T) {
+	communicationService, _ := newTestGameNode(t, game.Settings{
+		ZombieCoordinateUpdateInterval: time.Hour,
+	})
+
+	alice := &recordingConnection{messages: make(chan string, 16)}
+	aliceConnectionID, err := communicationService.NewConnection(alice)
+	require.NoError(t, err)
+
+	sendTestClusterMessage(t, communicationService, aliceConnectionID, "START alice")
+	splitMessage := strings.Split(readTestClusterMessage(t, alice), " ")
+	require.Len(t, splitMessage, 2)
+
+	bob := &recordingConnection{messages: make(chan string, 16)}
+	bobConnectionID, err := communicationService.NewConnection(bob)
+	require.NoError(t, err)
+	joinTestClusterGame(t, communicationService, bobConnectionID, bob, splitMessage[1], "bob", alice)
+
+	communicationService.HandleDisconnect(context.Background(), aliceConnectionID)
+	assert.Equal(t, "LEFT alice", readTestClusterMessage(t, bob))
+	assert.Equal(t, "ROSTER bob", readTestClusterMessage(t, bob))
+	assert.Equal(t, "HOST bob", readTestClusterMessage(t, bob))
+}
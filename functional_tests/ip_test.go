@@ -0,0 +1,62 @@
+package functional_tests
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"nhooyr.io/websocket"
+
+	"github.com/ScruffyPants/talk-to-zombies/api"
+	"github.com/ScruffyPants/talk-to-zombies/communication"
+)
+
+const testIPAddress = "localhost:8086"
+
+func TestClientIPFilter(t *testing.T) {
+	router, err := api.NewRouter(api.RouterSettings{
+		Address:             testIPAddress,
+		WebsocketPath:       "/ws",
+		PingInterval:        time.Second,
+		PongWait:            2 * time.Second,
+		WriteWait:           time.Second,
+		TrustedProxies:      []string{"127.0.0.1"},
+		IPBans:              []string{"203.0.113.0/24"},
+		MaxConnectionsPerIP: 1,
+	}, communication.NewCommunicationService(), staticStatusProvider{})
+	require.NoError(t, err)
+
+	router.Start()
+	defer router.Shutdown(context.Background())
+
+	dial := func(header, value string) (*websocket.Conn, error) {
+		conn, _, err := websocket.Dial(context.Background(), "ws://"+testIPAddress+"/ws", &websocket.DialOptions{
+			HTTPHeader: http.Header{header: []string{value}},
+		})
+		return conn, err
+	}
+
+	_, err = dial("X-Forwarded-For", "203.0.113.5")
+	assert.Error(t, err, "banned ip must be refused")
+
+	conn, err := dial("X-Forwarded-For", "203.0.113.5, 198.51.100.8")
+	require.NoError(t, err, "spoofed first hop must be ignored")
+	require.NoError(t, conn.Close(websocket.StatusNormalClosure, "disconnect"))
+
+	conn, err = dial("Forwarded", `for="198.51.100.7:4711";proto=https`)
+	require.NoError(t, err)
+
+	_, err = dial("Forwarded", "for=198.51.100.7")
+	assert.Error(t, err, "second connection from the same ip must be refused")
+
+	require.NoError(t, router.UpdateIPFilter([]string{"198.51.100.0/24"}, 1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, _, err = conn.Read(ctx)
+	assert.Equal(t, websocket.StatusPolicyViolation, websocket.CloseStatus(err), "connection from newly banned ip must be closed")
+}
@@ -6,7 +6,10 @@ import (
 	"testing"
 )
 
-const testWSAddress = ":8082"
+const (
+	testWSAddress    = ":8082"
+	testAdminAddress = "localhost:8088"
+)
 
 func TestMain(m *testing.M) {
 	viper.Set("ws.address", testWSAddress)
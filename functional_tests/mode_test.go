@@ -0,0 +1,149 @@
+package functional_tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ScruffyPants/talk-to-zombies/communication"
+	"github.com/ScruffyPants/talk-to-zombies/game"
+)
+
+func newTestModeNode(t *testing.T) communication.Service {
+	communicationService, _ := newTestGameNode(t, game.Settings{
+		ZombieCoordinateUpdateInterval: time.Hour,
+		WallHealth:                     3,
+		Modes:                          game.ModeSettings{CoopWaves: 2, CoopWaveSize: 1, FFAKillTarget: 2},
+	},
+		game.ZombieType{Name: "Tank", Movement: game.MovementCharge, Points: 1},
+	)
+
+	return communicationService
+}
+
+func startTestModeGame(t *testing.T, communicationService communication.Service, mode string) (*recordingConnection, string, string) {
+	connection := &recordingConnection{messages: make(chan string, 16)}
+	connectionID, err := communicationService.NewConnection(connection)
+	require.NoError(t, err)
+
+	sendTestClusterMessage(t, communicationService, connectionID, "START alice "+mode)
+	splitMessage := strings.Split(readTestClusterMessage(t, connection), " ")
+	require.Len(t, splitMessage, 3)
+	assert.Equal(t, "GAME", splitMessage[0])
+	assert.Equal(t, "mode="+mode, splitMessage[2])
+
+	return connection, connectionID, splitMessage[1]
+}
+
+func TestCoopMode(t *testing.T) {
+	communicationService := newTestModeNode(t)
+	connection, connectionID, _ := startTestModeGame(t, communicationService, game.ModeCoop)
+
+	assert.Equal(t, "WAVE 1", readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "SHOOT 0 0")
+	assert.Equal(t, "BOOM alice 1 Tank", readTestClusterMessage(t, connection))
+	assert.Equal(t, "WAVE 2", readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "WEAPON shotgun")
+	assert.Equal(t, "WEAPON shotgun", readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "SHOOT 0 0")
+	assert.Equal(t, "BOOM alice 2 Tank Tank_2", readTestClusterMessage(t, connection))
+	assert.Equal(t, "GAMEOVER WIN", readTestClusterMessage(t, connection))
+}
+
+func TestVersusMode(t *testing.T) {
+	communicationService := newTestModeNode(t)
+	aliceConnection, aliceConnectionID, gameID := startTestModeGame(t, communicationService, game.ModeVersus)
+
+	bobConnection := &recordingConnection{messages: make(chan string, 16)}
+	bobConnectionID, err := communicationService.NewConnection(bobConnection)
+	require.NoError(t, err)
+	joinTestClusterGame(t, communicationService, bobConnectionID, bobConnection, gameID, "bob", aliceConnection)
+
+	// Both lanes have a zombie at (0, 0), alice may only shoot the one in her lane
+	sendTestClusterMessage(t, communicationService, aliceConnectionID, "SYNC")
+	assert.Equal(t, "SYNC tick=0 seq=0 width=11 height=30 players=alice:0,bob:0 zombies=Tank:0:0:alice,Tank_2:0:0:bob blips=",
+		readTestClusterMessage(t, aliceConnection))
+
+	sendTestClusterMessage(t, communicationService, aliceConnectionID, "SHOOT 0 0")
+	assert.Equal(t, "BOOM alice 1 Tank", readTestClusterMessage(t, aliceConnection))
+	assert.Equal(t, "BOOM alice 1 Tank", readTestClusterMessage(t, bobConnection))
+
+	communicationService.HandleDisconnect(context.Background(), bobConnectionID)
+	assert.Equal(t, "LEFT bob", readTestClusterMessage(t, aliceConnection))
+	assert.Equal(t, "ROSTER alice", readTestClusterMessage(t, aliceConnection))
+	assert.Equal(t, "GAMEOVER WINNER alice", readTestClusterMessage(t, aliceConnection))
+}
+
+func TestFreeForAllMode(t *testing.T) {
+	communicationService := newTestModeNode(t)
+	connection, connectionID, _ := startTestModeGame(t, communicationService, game.ModeFFA)
+
+	sendTestClusterMessage(t, communicationService, connectionID, "SHOOT 0 0")
+	assert.Equal(t, "BOOM alice 1 Tank", readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "SHOOT 0 0")
+	assert.Equal(t, "BOOM alice 1 Tank", readTestClusterMessage(t, connection))
+	assert.Equal(t, "GAMEOVER WINNER alice", readTestClusterMessage(t, connection))
+}
+
+func TestUnknownMode(t *testing.T) {
+	communicationService := newTestModeNode(t)
+
+	connection := &recordingConnection{messages: make(chan string, 16)}
+	connectionID, err := communicationService.NewConnection(connection)
+	require.NoError(t, err)
+
+	sendTestClusterMessage(t, communicationService, connectionID, "START alice battle-royale")
+	assert.Equal(t, game.ErrUnknownGameMode.Error(), readTestClusterMessage(t, connection))
+}
+
+func TestWallHealth(t *testing.T) {
+	communicationService, _ := newTestGameNode(t, game.Settings{
+		ZombieCoordinateUpdateInterval: time.Hour,
+		WallHealth:                     2,
+	},
+		game.ZombieType{Name: "Runner", MoveInterval: 5 * time.Millisecond, Movement: game.MovementCharge},
+	)
+
+	connection := &recordingConnection{messages: make(chan string, 256)}
+	connectionID, err := communicationService.NewConnection(connection)
+	require.NoError(t, err)
+
+	sendTestClusterMessage(t, communicationService, connectionID, "START alice")
+	require.True(t, strings.HasPrefix(readTestClusterMessage(t, connection), "GAME "))
+
+	// The breaching zombie is replaced, the game only ends once the wall falls
+	var results []string
+	for len(results) < 3 {
+		if message := readTestClusterMessage(t, connection); !strings.HasPrefix(message, "DELTA ") {
+			results = append(results, message)
+		}
+	}
+
+	assert.Equal(t, []string{"WALL 1", "WALL 0", "GAMEOVER LOSE"}, results)
+}
+
+func TestFreeForAllCountsKills(t *testing.T) {
+	communicationService, _ := newTestGameNode(t, game.Settings{
+		ZombieCoordinateUpdateInterval: time.Hour,
+		Modes:                          game.ModeSettings{FFAKillTarget: 2},
+	},
+		game.ZombieType{Name: "Tank", Movement: game.MovementCharge, Points: 5},
+	)
+	connection, connectionID, _ := startTestModeGame(t, communicationService, game.ModeFFA)
+
+	// The points of the first kill are past the target, the kills aren't yet
+	sendTestClusterMessage(t, communicationService, connectionID, "SHOOT 0 0")
+	assert.Equal(t, "BOOM alice 5 Tank", readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "SHOOT 0 0")
+	assert.Equal(t, "BOOM alice 5 Tank", readTestClusterMessage(t, connection))
+	assert.Equal(t, "GAMEOVER WINNER alice", readTestClusterMessage(t, connection))
+}
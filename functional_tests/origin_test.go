@@ -0,0 +1,68 @@
+package functional_tests
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"nhooyr.io/websocket"
+
+	"github.com/ScruffyPants/talk-to-zombies/api"
+	"github.com/ScruffyPants/talk-to-zombies/communication"
+)
+
+const testOriginAddress = "localhost:8085"
+
+func TestWebsocketOriginPolicy(t *testing.T) {
+	router, err := api.NewRouter(api.RouterSettings{
+		Address:        testOriginAddress,
+		WebsocketPath:  "/ws",
+		PingInterval:   time.Second,
+		PongWait:       2 * time.Second,
+		WriteWait:      time.Second,
+		AllowedOrigins: []string{"https://*.example.com"},
+	}, communication.NewCommunicationService(), staticStatusProvider{})
+	require.NoError(t, err)
+
+	router.Start()
+	defer router.Shutdown(context.Background())
+
+	for origin, allowed := range map[string]bool{
+		"":                              true,
+		"http://" + testOriginAddress:   true,
+		"https://play.example.com":      true,
+		"https://eu.play.example.com":   true,
+		"http://play.example.com":       false,
+		"https://example.com":           false,
+		"https://play.example.com.evil": false,
+		"https://third-party.invalid":   false,
+	} {
+		header := http.Header{}
+		if origin != "" {
+			header.Set("Origin", origin)
+		}
+
+		conn, _, err := websocket.Dial(context.Background(), "ws://"+testOriginAddress+"/ws", &websocket.DialOptions{HTTPHeader: header})
+		if !allowed {
+			assert.Error(t, err, origin)
+			continue
+		}
+
+		if assert.NoError(t, err, origin) {
+			require.NoError(t, conn.Close(websocket.StatusNormalClosure, "disconnect"))
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "http://"+testOriginAddress+"/healthz", nil)
+	require.NoError(t, err)
+	req.Header.Set("Origin", "https://play.example.com")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	assert.Equal(t, "https://play.example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+}
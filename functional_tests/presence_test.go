@@ -0,0 +1,49 @@
+package functional_tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ScruffyPants/talk-to-zombies/game"
+)
+
+func TestPlayerPresence(t *testing.T) {
+	communicationService, _ := newTestGameNode(t, game.Settings{
+		ZombieCoordinateUpdateInterval: time.Hour,
+		Zombies:                        2,
+	}, game.ZombieType{Name: "Tank", Movement: game.MovementCharge})
+
+	alice := &recordingConnection{messages: make(chan string, 16)}
+	aliceConnectionID, err := communicationService.NewConnection(alice)
+	require.NoError(t, err)
+
+	sendTestClusterMessage(t, communicationService, aliceConnectionID, "START alice")
+	splitMessage := strings.Split(readTestClusterMessage(t, alice), " ")
+	require.Len(t, splitMessage, 2)
+	gameID := splitMessage[1]
+
+	sendTestClusterMessage(t, communicationService, aliceConnectionID, "PLAYERS")
+	assert.Equal(t, "ROSTER alice", readTestClusterMessage(t, alice))
+
+	bob := &recordingConnection{messages: make(chan string, 16)}
+	bobConnectionID, err := communicationService.NewConnection(bob)
+	require.NoError(t, err)
+
+	joinTestClusterGame(t, communicationService, bobConnectionID, bob, gameID, "bob")
+
+	assert.Equal(t, "JOINED bob", readTestClusterMessage(t, alice))
+	assert.Equal(t, "ROSTER alice bob", readTestClusterMessage(t, alice))
+
+	sendTestClusterMessage(t, communicationService, bobConnectionID, "PLAYERS")
+	assert.Equal(t, "ROSTER alice bob", readTestClusterMessage(t, bob))
+
+	communicationService.HandleDisconnect(context.Background(), bobConnectionID)
+	assert.Equal(t, "LEFT bob", readTestClusterMessage(t, alice))
+	assert.Equal(t, "ROSTER alice", readTestClusterMessage(t, alice))
+	assert.Empty(t, bob.messages)
+}
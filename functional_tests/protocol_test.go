@@ -0,0 +1,118 @@
+package functional_tests
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"nhooyr.io/websocket"
+
+	"github.com/ScruffyPants/talk-to-zombies/protocol"
+)
+
+func TestBinaryProtocolGame(t *testing.T) {
+	wsConnection, _, err := websocket.Dial(context.Background(), fmt.Sprintf("ws://%s/ws", testWSAddress), &websocket.DialOptions{
+		Subprotocols: []string{protocol.Subprotocol},
+	})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, wsConnection.Close(websocket.StatusNormalClosure, "disconnect"))
+	}()
+
+	require.Equal(t, protocol.Subprotocol, wsConnection.Subprotocol())
+
+	name := newTestUsername()
+
+	testWriteBinaryFrame(t, wsConnection, protocol.Start{Username: name})
+	game, ok := testReadBinaryFrame(t, wsConnection).(protocol.Game)
+	require.True(t, ok)
+	assert.NotEmpty(t, game.GameID)
+
+	names := map[uint64]string{}
+
+	// Text commands are answered in frames too
+	require.NoError(t, wsConnection.Write(context.Background(), websocket.MessageText, []byte("SYNC")))
+	assert.Contains(t, testReadTextFrame(t, wsConnection, names), "SYNC ")
+
+	testWriteBinaryFrame(t, wsConnection, protocol.Command{Text: "FOO"})
+	assert.NotEmpty(t, testReadTextFrame(t, wsConnection, names))
+
+	// The zombie is named before the first WALK refers to it
+	var walk protocol.Walk
+	for len(walk.Zombies) == 0 {
+		switch frame := testReadBinaryFrame(t, wsConnection).(type) {
+		case protocol.Name:
+			names[frame.ID] = frame.Name
+		case protocol.Walk:
+			walk = frame
+		}
+	}
+
+	require.Len(t, walk.Zombies, 1)
+	zombie := walk.Zombies[0]
+	require.Contains(t, names, zombie.ID)
+
+	for {
+		testWriteBinaryFrame(t, wsConnection, protocol.Shoot{X: zombie.X, Y: zombie.Y})
+
+		frame := testReadBinaryFrame(t, wsConnection)
+		if walk, ok := frame.(protocol.Walk); ok {
+			zombie = walk.Zombies[0]
+			frame = testReadBinaryFrame(t, wsConnection)
+		}
+
+		boom, ok := frame.(protocol.Boom)
+		require.True(t, ok)
+		assert.Equal(t, name, boom.Username)
+
+		if len(boom.Hits) == 1 && boom.Hits[0].HitPoints == 0 {
+			assert.Equal(t, zombie.ID, boom.Hits[0].ID)
+			break
+		}
+
+		time.Sleep(timeoutBetweenShootCommands)
+	}
+
+	gameOver, ok := testReadBinaryFrame(t, wsConnection).(protocol.GameOver)
+	require.True(t, ok)
+	assert.Equal(t, protocol.ResultWin, gameOver.Result)
+}
+
+func testWriteBinaryFrame(t *testing.T, wsConnection *websocket.Conn, frame protocol.Frame) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	require.NoError(t, wsConnection.Write(ctx, websocket.MessageBinary, protocol.Marshal(frame)))
+}
+
+func testReadBinaryFrame(t *testing.T, wsConnection *websocket.Conn) protocol.Frame {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+
+	messageType, data, err := wsConnection.Read(ctx)
+	require.NoError(t, err)
+	require.Equal(t, websocket.MessageBinary, messageType)
+
+	frame, err := protocol.Unmarshal(data)
+	require.NoError(t, err)
+
+	return frame
+}
+
+// testReadTextFrame skips the zombies walking, remembering their names
+func testReadTextFrame(t *testing.T, wsConnection *websocket.Conn, names map[uint64]string) string {
+	for {
+		switch frame := testReadBinaryFrame(t, wsConnection).(type) {
+		case protocol.Name:
+			names[frame.ID] = frame.Name
+		case protocol.Walk:
+		case protocol.Text:
+			return frame.Text
+		default:
+			t.Fatalf("unexpected frame %#v", frame)
+		}
+	}
+}
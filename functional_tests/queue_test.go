@@ -0,0 +1,204 @@
+package functional_tests
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ScruffyPants/talk-to-zombies/communication"
+	"github.com/ScruffyPants/talk-to-zombies/game"
+)
+
+// slowConnection only takes a message once the test releases it
+type slowConnection struct {
+	taken   chan string
+	release chan struct{}
+	closed  chan struct{}
+}
+
+func newSlowConnection() *slowConnection {
+	return &slowConnection{
+		taken:   make(chan string, 16),
+		release: make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+}
+
+func (c *slowConnection) SendMessage(message []byte) error {
+	c.taken <- string(message)
+	<-c.release
+	return nil
+}
+
+func (c *slowConnection) Close() error {
+	close(c.closed)
+	return nil
+}
+
+func readTestTakenMessage(t *testing.T, connection *slowConnection) string {
+	select {
+	case message := <-connection.taken:
+		return message
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for message")
+		return ""
+	}
+}
+
+func outboundMetric(name string) int64 {
+	metric := expvar.Get("outbound").(*expvar.Map).Get(name)
+	if metric == nil {
+		return 0
+	}
+
+	return metric.(*expvar.Int).Value()
+}
+
+func TestOutboundQueueDropsPositionUpdates(t *testing.T) {
+	connection := newSlowConnection()
+	queued := communication.NewQueuedConnection(connection, communication.QueueSettings{
+		Size:      2,
+		Policy:    communication.OverflowDrop,
+		Droppable: game.IsPositionUpdate,
+	})
+	defer queued.Stop()
+
+	dropped := outboundMetric("dropped")
+
+	// The first message is held up by the client, the others wait in the queue
+	require.NoError(t, queued.SendMessage([]byte("DELTA seq=1 zombies=Tank:0:1 blips= gone=")))
+	assert.Equal(t, "DELTA seq=1 zombies=Tank:0:1 blips= gone=", readTestTakenMessage(t, connection))
+
+	for _, message := range []string{
+		"DELTA seq=2 zombies=Tank:0:2 blips= gone=",
+		"BOOM alice 1 Tank",
+		"DELTA seq=3 zombies=Tank:0:3 blips= gone=",
+		"GAMEOVER WIN",
+		"DELTA seq=4 zombies=Tank:0:4 blips= gone=",
+	} {
+		require.NoError(t, queued.SendMessage([]byte(message)))
+	}
+
+	var received []string
+	for j := 0; j < 2; j++ {
+		connection.release <- struct{}{}
+		received = append(received, readTestTakenMessage(t, connection))
+	}
+	connection.release <- struct{}{}
+
+	assert.Equal(t, []string{"BOOM alice 1 Tank", "GAMEOVER WIN"}, received)
+	assert.Equal(t, dropped+3, outboundMetric("dropped"))
+}
+
+func TestOutboundQueueKeepsGoneZombies(t *testing.T) {
+	connection := newSlowConnection()
+	queued := communication.NewQueuedConnection(connection, communication.QueueSettings{
+		Size:      2,
+		Policy:    communication.OverflowDrop,
+		Droppable: game.IsPositionUpdate,
+	})
+	defer queued.Stop()
+
+	require.NoError(t, queued.SendMessage([]byte("DELTA seq=1 zombies=Tank:0:1 blips= gone=")))
+	assert.Equal(t, "DELTA seq=1 zombies=Tank:0:1 blips= gone=", readTestTakenMessage(t, connection))
+
+	// No later DELTA reports the despawned zombie again, so its DELTA outlasts the overflow
+	for _, message := range []string{
+		"DELTA seq=2 zombies= blips= gone=Tank",
+		"DELTA seq=3 zombies=Tank_2:0:1 blips= gone=",
+		"DELTA seq=4 zombies=Tank_2:0:2 blips= gone=",
+		"DELTA seq=5 zombies=Tank_2:0:3 blips= gone=",
+	} {
+		require.NoError(t, queued.SendMessage([]byte(message)))
+	}
+
+	var received []string
+	for j := 0; j < 2; j++ {
+		connection.release <- struct{}{}
+		received = append(received, readTestTakenMessage(t, connection))
+	}
+	connection.release <- struct{}{}
+
+	assert.Equal(t, []string{"DELTA seq=2 zombies= blips= gone=Tank", "DELTA seq=5 zombies=Tank_2:0:3 blips= gone="}, received)
+}
+
+func TestOutboundQueueDisconnectsSlowConsumers(t *testing.T) {
+	connection := newSlowConnection()
+	queued := communication.NewQueuedConnection(connection, communication.QueueSettings{
+		Size:         1,
+		Policy:       communication.OverflowDisconnect,
+		MaxOverflows: 2,
+		Droppable:    game.IsPositionUpdate,
+	})
+	defer close(connection.release)
+
+	disconnects := outboundMetric("disconnects")
+
+	require.NoError(t, queued.SendMessage([]byte("DELTA seq=1 zombies=Tank:0:1 blips= gone=")))
+	assert.Equal(t, "DELTA seq=1 zombies=Tank:0:1 blips= gone=", readTestTakenMessage(t, connection))
+
+	require.NoError(t, queued.SendMessage([]byte("DELTA seq=2 zombies=Tank:0:2 blips= gone=")))
+	require.NoError(t, queued.SendMessage([]byte("DELTA seq=3 zombies=Tank:0:3 blips= gone=")))
+	assert.ErrorIs(t, queued.SendMessage([]byte("DELTA seq=4 zombies=Tank:0:4 blips= gone=")), communication.ErrSlowConsumer)
+
+	select {
+	case <-connection.closed:
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for the connection to close")
+	}
+
+	assert.ErrorIs(t, queued.SendMessage([]byte("GAMEOVER WIN")), communication.ErrConnectionClosed)
+	assert.Equal(t, disconnects+1, outboundMetric("disconnects"))
+}
+
+func TestOutboundQueueNeverGrowsPastItsSize(t *testing.T) {
+	connection := newSlowConnection()
+	queued := communication.NewQueuedConnection(connection, communication.QueueSettings{
+		Size:      1,
+		Policy:    communication.OverflowDrop,
+		Droppable: game.IsPositionUpdate,
+	})
+	defer close(connection.release)
+
+	disconnects := outboundMetric("disconnects")
+
+	require.NoError(t, queued.SendMessage([]byte("BOOM alice 1 Tank")))
+	assert.Equal(t, "BOOM alice 1 Tank", readTestTakenMessage(t, connection))
+
+	// Neither the queued message nor the new one can be dropped, so the client is let go
+	require.NoError(t, queued.SendMessage([]byte("WAVE 2")))
+	assert.ErrorIs(t, queued.SendMessage([]byte("GAMEOVER WIN")), communication.ErrSlowConsumer)
+
+	select {
+	case <-connection.closed:
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for the connection to close")
+	}
+
+	assert.Equal(t, disconnects+1, outboundMetric("disconnects"))
+}
+
+func TestOutboundMetricsEndpoint(t *testing.T) {
+	// The metrics are only served on the admin address
+	resp, err := http.Get(fmt.Sprintf("http://localhost%s/debug/vars", testWSAddress))
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	resp, err = http.Get(fmt.Sprintf("http://%s/debug/vars", testAdminAddress))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var vars map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&vars))
+
+	assert.Contains(t, vars, "outbound")
+}
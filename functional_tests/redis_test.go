@@ -0,0 +1,392 @@
+package functional_tests
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ScruffyPants/talk-to-zombies/cluster"
+	"github.com/ScruffyPants/talk-to-zombies/communication"
+)
+
+// respServer speaks just enough of the Redis protocol for the redis backend: SET (NX, PX),
+// GET, DEL, PEXPIRE, PUBLISH, SUBSCRIBE and EVAL of the backend's compare-and-delete script
+type respServer struct {
+	listener net.Listener
+	// stall holds up commands it returns true for until it is closed
+	stall     func(args []string) bool
+	unstalled chan struct{}
+
+	mu          sync.Mutex
+	values      map[string]string
+	expires     map[string]time.Time
+	subscribers map[string][]*respServerConn
+}
+
+type respServerConn struct {
+	mu     sync.Mutex
+	writer *bufio.Writer
+}
+
+func newRESPServer(t *testing.T) *respServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &respServer{
+		listener:    listener,
+		unstalled:   make(chan struct{}),
+		values:      map[string]string{},
+		expires:     map[string]time.Time{},
+		subscribers: map[string][]*respServerConn{},
+	}
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+
+	go s.serve()
+
+	return s
+}
+
+func (s *respServer) address() string {
+	return s.listener.Addr().String()
+}
+
+func (s *respServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go s.handle(conn)
+	}
+}
+
+func (s *respServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	c := &respServerConn{writer: bufio.NewWriter(conn)}
+
+	for {
+		args, err := readRESPCommand(reader)
+		if err != nil {
+			return
+		}
+
+		if s.stall != nil && s.stall(args) {
+			<-s.unstalled
+		}
+
+		c.write(s.do(c, args))
+	}
+}
+
+func (c *respServerConn) write(reply string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, _ = c.writer.WriteString(reply)
+	_ = c.writer.Flush()
+}
+
+func (s *respServer) do(c *respServerConn, args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch strings.ToUpper(args[0]) {
+	case "SET":
+		key, value := args[1], args[2]
+		_, exists := s.getLocked(key)
+
+		var ttl time.Duration
+		for j := 3; j < len(args); j++ {
+			switch strings.ToUpper(args[j]) {
+			case "NX":
+				if exists {
+					return "$-1\r\n"
+				}
+			case "PX":
+				j++
+				ms, _ := strconv.Atoi(args[j])
+				ttl = time.Duration(ms) * time.Millisecond
+			}
+		}
+
+		s.values[key] = value
+		delete(s.expires, key)
+		if ttl > 0 {
+			s.expires[key] = time.Now().Add(ttl)
+		}
+
+		return "+OK\r\n"
+	case "GET":
+		value, ok := s.getLocked(args[1])
+		if !ok {
+			return "$-1\r\n"
+		}
+
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	case "DEL":
+		_, ok := s.getLocked(args[1])
+		delete(s.values, args[1])
+		delete(s.expires, args[1])
+
+		return respInteger(ok)
+	case "EVAL":
+		// The only script is the release of a claim: EVAL {script} 1 {key} {node}
+		if value, ok := s.getLocked(args[3]); !ok || value != args[4] {
+			return ":0\r\n"
+		}
+		delete(s.values, args[3])
+		delete(s.expires, args[3])
+
+		return ":1\r\n"
+	case "PEXPIRE":
+		_, ok := s.getLocked(args[1])
+		if ok {
+			ms, _ := strconv.Atoi(args[2])
+			s.expires[args[1]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		}
+
+		return respInteger(ok)
+	case "PUBLISH":
+		subscribers := s.subscribers[args[1]]
+		for _, subscriber := range subscribers {
+			go subscriber.write(fmt.Sprintf("*3\r\n$7\r\nmessage\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n", len(args[1]), args[1], len(args[2]), args[2]))
+		}
+
+		return fmt.Sprintf(":%d\r\n", len(subscribers))
+	case "SUBSCRIBE":
+		s.subscribers[args[1]] = append(s.subscribers[args[1]], c)
+
+		return fmt.Sprintf("*3\r\n$9\r\nsubscribe\r\n$%d\r\n%s\r\n:1\r\n", len(args[1]), args[1])
+	default:
+		return fmt.Sprintf("-ERR unknown command '%s'\r\n", args[0])
+	}
+}
+
+// getLocked returns the value of the key unless it expired
+func (s *respServer) getLocked(key string) (string, bool) {
+	if expires, ok := s.expires[key]; ok && time.Now().After(expires) {
+		delete(s.values, key)
+		delete(s.expires, key)
+	}
+
+	value, ok := s.values[key]
+	return value, ok
+}
+
+func respInteger(ok bool) string {
+	if ok {
+		return ":1\r\n"
+	}
+
+	return ":0\r\n"
+}
+
+func readRESPCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	count, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, 0, count)
+	for j := 0; j < count; j++ {
+		if line, err = reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+
+		length, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+
+		buf := make([]byte, length+2)
+		if _, err = io.ReadFull(reader, buf); err != nil {
+			return nil, err
+		}
+
+		args = append(args, string(buf[:length]))
+	}
+
+	return args, nil
+}
+
+func TestRedisBackendGameClaims(t *testing.T) {
+	server := newRESPServer(t)
+	ctx := context.Background()
+
+	backend := cluster.NewRedisBackend(server.address(), 100*time.Millisecond)
+	t.Cleanup(func() {
+		require.NoError(t, backend.Close())
+	})
+
+	claimed, err := backend.ClaimGame(ctx, "game", "first")
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	claimed, err = backend.ClaimGame(ctx, "game", "second")
+	require.NoError(t, err)
+	assert.False(t, claimed)
+
+	owner, err := backend.GetGameOwner(ctx, "game")
+	require.NoError(t, err)
+	assert.Equal(t, "first", owner)
+
+	renewed, err := backend.RenewGame(ctx, "game", "second")
+	require.NoError(t, err)
+	assert.False(t, renewed)
+
+	renewed, err = backend.RenewGame(ctx, "game", "first")
+	require.NoError(t, err)
+	assert.True(t, renewed)
+
+	// A node which stops renewing, f.x. because it crashed, loses its games
+	time.Sleep(150 * time.Millisecond)
+
+	_, err = backend.GetGameOwner(ctx, "game")
+	assert.ErrorIs(t, err, cluster.ErrGameNotFound)
+
+	claimed, err = backend.ClaimGame(ctx, "game", "second")
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	// The first node's claim expired, releasing the game leaves the second node's claim alone
+	require.NoError(t, backend.ReleaseGame(ctx, "game", "first"))
+
+	owner, err = backend.GetGameOwner(ctx, "game")
+	require.NoError(t, err)
+	assert.Equal(t, "second", owner)
+
+	require.NoError(t, backend.ReleaseGame(ctx, "game", "second"))
+
+	_, err = backend.GetGameOwner(ctx, "game")
+	assert.ErrorIs(t, err, cluster.ErrGameNotFound)
+}
+
+func TestRedisBackendSlowReplyHoldsUpOnlyItsCaller(t *testing.T) {
+	server := newRESPServer(t)
+	server.stall = func(args []string) bool {
+		return strings.ToUpper(args[0]) == "GET" && strings.HasSuffix(args[1], "slow")
+	}
+	ctx := context.Background()
+
+	backend := cluster.NewRedisBackend(server.address(), 0)
+	t.Cleanup(func() {
+		require.NoError(t, backend.Close())
+	})
+
+	stalled := make(chan error, 1)
+	go func() {
+		_, err := backend.GetGameOwner(ctx, "slow")
+		stalled <- err
+	}()
+
+	claimCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+
+	claimed, err := backend.ClaimGame(claimCtx, "game", "node")
+	require.NoError(t, err)
+	assert.True(t, claimed)
+
+	close(server.unstalled)
+	assert.ErrorIs(t, <-stalled, cluster.ErrGameNotFound)
+}
+
+func TestRedisBackendPublish(t *testing.T) {
+	server := newRESPServer(t)
+	ctx := context.Background()
+
+	backend := cluster.NewRedisBackend(server.address(), 0)
+	t.Cleanup(func() {
+		require.NoError(t, backend.Close())
+	})
+
+	assert.ErrorIs(t, backend.Publish(ctx, "node", cluster.Envelope{Type: cluster.EnvelopeDisconnect}), cluster.ErrNodeNotFound)
+
+	subscription, err := backend.Subscribe(ctx, "node")
+	require.NoError(t, err)
+
+	envelope := cluster.Envelope{
+		Type:         cluster.EnvelopeMessage,
+		SourceNodeID: "other",
+		ConnectionID: "connection",
+		Message:      communication.Message{Type: "SHOOT", Arguments: []string{"1", "2"}},
+	}
+	require.NoError(t, backend.Publish(ctx, "node", envelope))
+
+	select {
+	case received := <-subscription:
+		assert.Equal(t, envelope, received)
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for envelope")
+	}
+}
+
+func TestClusterNodeRenewsClaims(t *testing.T) {
+	server := newRESPServer(t)
+
+	settings := cluster.Settings{NodeID: "node", GameTTL: 90 * time.Millisecond}
+	backend := cluster.NewRedisBackend(server.address(), settings.GameTTL)
+	t.Cleanup(func() {
+		require.NoError(t, backend.Close())
+	})
+
+	node, err := cluster.NewClusterNode(settings, backend, communication.NewCommunicationService())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		node.Close(context.Background())
+	})
+
+	claimed, err := node.ClaimGame(context.Background(), "game")
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	time.Sleep(3 * settings.GameTTL)
+
+	owner, err := backend.GetGameOwner(context.Background(), "game")
+	require.NoError(t, err)
+	assert.Equal(t, "node", owner)
+
+	// A node shutting down gives its games up for the next one
+	node.Close(context.Background())
+
+	_, err = backend.GetGameOwner(context.Background(), "game")
+	assert.ErrorIs(t, err, cluster.ErrGameNotFound)
+}
+
+func TestMemoryBackendPublishDoesNotBlockForever(t *testing.T) {
+	backend := cluster.NewMemoryBackend()
+	t.Cleanup(func() {
+		require.NoError(t, backend.Close())
+	})
+
+	// Nobody reads the subscription, so it fills up
+	_, err := backend.Subscribe(context.Background(), "node")
+	require.NoError(t, err)
+
+	for {
+		if err = backend.Publish(context.Background(), "node", cluster.Envelope{Type: cluster.EnvelopeDisconnect}); err != nil {
+			break
+		}
+	}
+
+	assert.ErrorIs(t, err, cluster.ErrNodeBusy)
+}
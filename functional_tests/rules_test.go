@@ -0,0 +1,71 @@
+package functional_tests
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ScruffyPants/talk-to-zombies/game"
+)
+
+func TestStartRules(t *testing.T) {
+	communicationService, _ := newTestGameNode(t, game.Settings{
+		ZombieCoordinateUpdateInterval: time.Hour,
+		Rules: game.RuleBounds{
+			MinZombies: 1, MaxZombies: 5,
+			MinInterval: time.Minute, MaxInterval: 2 * time.Hour,
+			MinWidth: 5, MaxWidth: 20,
+			MinWallHealth: 1, MaxWallHealth: 5,
+		},
+	},
+		game.ZombieType{Name: "Tank", Movement: game.MovementCharge, Points: 1},
+	)
+
+	connection := &recordingConnection{messages: make(chan string, 16)}
+	connectionID, err := communicationService.NewConnection(connection)
+	require.NoError(t, err)
+
+	sendTestClusterMessage(t, communicationService, connectionID, "START alice zombies=50")
+	assert.Equal(t, "invalid game rule: zombies must be a number between 1 and 5", readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "START alice speed=11")
+	assert.True(t, strings.HasPrefix(readTestClusterMessage(t, connection), "invalid game rule: unknown rule speed"))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "START alice zombies=2 interval=90m width=20")
+	splitMessage := strings.Split(readTestClusterMessage(t, connection), " ")
+	require.Len(t, splitMessage, 5)
+	assert.Equal(t, "GAME", splitMessage[0])
+	assert.Equal(t, []string{"zombies=2", "interval=1h30m0s", "width=20"}, splitMessage[2:])
+
+	// Both zombies wait at (0, 0)
+	sendTestClusterMessage(t, communicationService, connectionID, "WEAPON shotgun")
+	assert.Equal(t, "WEAPON shotgun", readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "SHOOT 0 0")
+	assert.Equal(t, "BOOM alice 2 Tank Tank_2", readTestClusterMessage(t, connection))
+	assert.Equal(t, "GAMEOVER WIN", readTestClusterMessage(t, connection))
+}
+
+func TestStartRulesIntervalScalesZombieTypes(t *testing.T) {
+	communicationService, _ := newTestGameNode(t, game.Settings{
+		ZombieCoordinateUpdateInterval: time.Hour,
+		Rules:                          game.RuleBounds{MinInterval: 10 * time.Millisecond, MaxInterval: time.Hour, MinWidth: 1, MaxWidth: 1},
+	},
+		game.ZombieType{Name: "Skipper", MoveInterval: 30 * time.Minute, Movement: game.MovementCharge, Points: 1},
+	)
+
+	connection := &recordingConnection{messages: make(chan string, 16)}
+	connectionID, err := communicationService.NewConnection(connection)
+	require.NoError(t, err)
+
+	// The Skipper steps twice as often as the game's interval, whatever the game picks
+	sendTestClusterMessage(t, communicationService, connectionID, "START alice interval=100ms width=1")
+	require.True(t, strings.HasPrefix(readTestClusterMessage(t, connection), "GAME "))
+
+	started := time.Now()
+	assert.Equal(t, "DELTA seq=1 zombies=Skipper:0:1 blips= gone=", readTestClusterMessage(t, connection))
+	assert.Less(t, time.Since(started), time.Second)
+}
@@ -0,0 +1,173 @@
+package functional_tests
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ScruffyPants/talk-to-zombies/cluster"
+	"github.com/ScruffyPants/talk-to-zombies/game"
+)
+
+func newTestSnapshotSettings(t *testing.T) game.Settings {
+	zombieTypes, err := game.NewZombieTypeRegistry([]game.ZombieType{
+		{Name: "Runner", MoveInterval: 20 * time.Millisecond, Movement: game.MovementCharge, Points: 1},
+	})
+	require.NoError(t, err)
+
+	return game.Settings{
+		ZombieCoordinateUpdateInterval: time.Hour,
+		ZombieTypes:                    zombieTypes,
+		BoardWidth:                     1,
+		WallHealth:                     100,
+		Modes:                          game.ModeSettings{CoopWaves: 10, CoopWaveSize: 1},
+	}
+}
+
+func TestGameRestoredFromSnapshot(t *testing.T) {
+	snapshotStore := game.NewFileSnapshotStore(filepath.Join(t.TempDir(), "snapshots.json"))
+	settings := newTestSnapshotSettings(t)
+
+	var seatToken string
+	gameID := func() string {
+		backend := cluster.NewMemoryBackend()
+		defer backend.Close()
+
+		communicationService, gameComponent := newTestClusterNodeWithSettings(t, backend, snapshotStore, settings)
+
+		connection := &recordingConnection{messages: make(chan string, 256)}
+		connectionID, err := communicationService.NewConnection(connection)
+		require.NoError(t, err)
+		defer communicationService.HandleDisconnect(context.Background(), connectionID)
+
+		sendTestClusterMessage(t, communicationService, connectionID, "START alice coop")
+		splitMessage := strings.Split(readTestClusterMessage(t, connection), " ")
+		require.Len(t, splitMessage, 3)
+
+		seat := strings.Split(readTestClusterMessage(t, connection), " ")
+		require.Len(t, seat, 2)
+		require.Equal(t, "SEAT", seat[0])
+		seatToken = seat[1]
+
+		require.Equal(t, "WAVE 1", readTestClusterMessage(t, connection))
+
+		// The zombies walked and a kill scored and spawned the next wave, moving the random generator on
+		require.True(t, strings.HasPrefix(readTestClusterMessage(t, connection), "DELTA "))
+
+		sendTestClusterMessage(t, communicationService, connectionID, "WEAPON sniper")
+		require.True(t, strings.HasPrefix(readTestClusterMessageSkippingDeltas(t, connection), "WEAPON sniper"))
+
+		sendTestClusterMessage(t, communicationService, connectionID, "SHOOT 0 0")
+		require.True(t, strings.HasPrefix(readTestClusterMessageSkippingDeltas(t, connection), "BOOM alice 1 "))
+		require.Equal(t, "WAVE 2", readTestClusterMessageSkippingDeltas(t, connection))
+
+		require.NoError(t, gameComponent.SaveSnapshots())
+
+		return splitMessage[1]
+	}()
+
+	snapshots, err := snapshotStore.Load()
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+
+	saved := snapshots[0]
+	assert.Equal(t, gameID, saved.GameID)
+	assert.NotZero(t, saved.TickCount)
+	require.NotEmpty(t, saved.Zombies)
+	require.Len(t, saved.Players, 1)
+	assert.Equal(t, 1, saved.Players[0].Score)
+	assert.Equal(t, seatToken, saved.Players[0].SeatToken)
+	assert.Equal(t, 2, saved.Wave)
+
+	communicationService, gameComponent := newTestClusterNodeWithSettings(t, newTestMemoryBackend(t), snapshotStore, settings)
+
+	// The restored game waits for its players, so it is snapshotted as it was saved
+	require.NoError(t, gameComponent.SaveSnapshots())
+
+	snapshots, err = snapshotStore.Load()
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+
+	restored := snapshots[0]
+	assert.Equal(t, saved.Zombies, restored.Zombies)
+	assert.Equal(t, saved.Players, restored.Players)
+	assert.Equal(t, saved.TickCount, restored.TickCount)
+	assert.Equal(t, saved.RNGState, restored.RNGState)
+
+	connection := &recordingConnection{messages: make(chan string, 256)}
+	connectionID, err := communicationService.NewConnection(connection)
+	require.NoError(t, err)
+
+	var zombies []string
+	for _, z := range saved.Zombies {
+		zombies = append(zombies, fmt.Sprintf("%s:%d:%d", z.Name, z.X, z.Y))
+	}
+
+	// Nobody else can take alice's seat while it waits for her
+	sendTestClusterMessage(t, communicationService, connectionID, "JOIN "+gameID+" alice")
+	assert.Equal(t, game.ErrSeatReserved.Error(), readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "JOIN "+gameID+" alice "+seatToken)
+	assert.Equal(t, "WELCOME "+gameID+" alice", readTestClusterMessage(t, connection))
+	assert.Equal(t, fmt.Sprintf("SYNC tick=%d seq=0 width=1 height=30 players=alice:1 zombies=%s blips=", saved.TickCount, strings.Join(zombies, ",")),
+		readTestClusterMessage(t, connection))
+	assert.Equal(t, "SEAT "+seatToken, readTestClusterMessage(t, connection))
+}
+
+func TestRestoredGameKeepsKicksAndMutes(t *testing.T) {
+	snapshotStore := game.NewFileSnapshotStore(filepath.Join(t.TempDir(), "snapshots.json"))
+	saved := game.Snapshot{
+		GameID:  "kicks",
+		Mode:    game.ModeClassic,
+		Players: []game.PlayerSnapshot{{Username: "alice", SeatToken: "token"}},
+		Kicked:  []string{"mallory"},
+		Mutes:   map[string][]string{"alice": {"mallory"}},
+	}
+	require.NoError(t, snapshotStore.Save([]game.Snapshot{saved}))
+
+	communicationService, gameComponent := newTestClusterNodeWithSettings(t, newTestMemoryBackend(t), snapshotStore, game.Settings{
+		ZombieCoordinateUpdateInterval: time.Hour,
+	})
+
+	connection := &recordingConnection{messages: make(chan string, 256)}
+	connectionID, err := communicationService.NewConnection(connection)
+	require.NoError(t, err)
+
+	sendTestClusterMessage(t, communicationService, connectionID, "JOIN kicks mallory")
+	assert.Equal(t, game.ErrPlayerKicked.Error(), readTestClusterMessage(t, connection))
+
+	require.NoError(t, gameComponent.SaveSnapshots())
+
+	snapshots, err := snapshotStore.Load()
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+	assert.Equal(t, saved.Kicked, snapshots[0].Kicked)
+	assert.Equal(t, saved.Mutes, snapshots[0].Mutes)
+	assert.Equal(t, saved.Players, snapshots[0].Players)
+}
+
+func TestRestoredGameExpiresWithoutPlayers(t *testing.T) {
+	snapshotStore := game.NewFileSnapshotStore(filepath.Join(t.TempDir(), "snapshots.json"))
+	require.NoError(t, snapshotStore.Save([]game.Snapshot{{GameID: "abandoned", Mode: game.ModeClassic}}))
+
+	backend := newTestMemoryBackend(t)
+
+	_, gameComponent := newTestClusterNodeWithSettings(t, backend, snapshotStore, game.Settings{
+		ZombieCoordinateUpdateInterval: time.Hour,
+		SnapshotRejoinTimeout:          50 * time.Millisecond,
+	})
+	require.Equal(t, 1, gameComponent.GameCount())
+
+	assert.Eventually(t, func() bool {
+		return gameComponent.GameCount() == 0
+	}, 3*time.Second, 10*time.Millisecond)
+
+	_, err := backend.GetGameOwner(context.Background(), "abandoned")
+	assert.ErrorIs(t, err, cluster.ErrGameNotFound)
+}
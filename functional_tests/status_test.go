@@ -0,0 +1,83 @@
+package functional_tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ScruffyPants/talk-to-zombies/api"
+	"github.com/ScruffyPants/talk-to-zombies/communication"
+)
+
+const testDrainAddress = "localhost:8087"
+
+func TestStatusEndpoints(t *testing.T) {
+	for _, path := range []string{"/healthz", "/readyz"} {
+		resp, err := http.Get(fmt.Sprintf("http://localhost%s%s", testWSAddress, path))
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode, path)
+	}
+
+	resp, err := http.Get(fmt.Sprintf("http://localhost%s/version", testWSAddress))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var version map[string]interface{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&version))
+
+	assert.NotEmpty(t, version["version"])
+	assert.NotEmpty(t, version["uptime"])
+	assert.Contains(t, version, "games")
+}
+
+func TestShutdownDrainsBeforeClosing(t *testing.T) {
+	router, err := api.NewRouter(api.RouterSettings{
+		Address:       testDrainAddress,
+		WebsocketPath: "/ws",
+		PingInterval:  time.Second,
+		PongWait:      2 * time.Second,
+		WriteWait:     time.Second,
+		DrainPeriod:   time.Second,
+	}, communication.NewCommunicationService(), staticStatusProvider{})
+	require.NoError(t, err)
+
+	router.Start()
+
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- router.Shutdown(context.Background())
+	}()
+
+	// The server keeps answering during the drain period, telling load balancers it is going away
+	assert.Eventually(t, func() bool {
+		resp, err := http.Get("http://" + testDrainAddress + "/readyz")
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+
+		var status map[string]string
+		return json.NewDecoder(resp.Body).Decode(&status) == nil &&
+			resp.StatusCode == http.StatusServiceUnavailable && status["status"] == "draining"
+	}, 500*time.Millisecond, 10*time.Millisecond)
+
+	select {
+	case err = <-shutdown:
+		require.NoError(t, err)
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for shutdown")
+	}
+
+	_, err = http.Get("http://" + testDrainAddress + "/readyz")
+	assert.Error(t, err)
+}
@@ -0,0 +1,141 @@
+package functional_tests
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ScruffyPants/talk-to-zombies/game"
+)
+
+func TestLateJoinSync(t *testing.T) {
+	communicationService, _ := newTestGameNode(t, game.Settings{
+		ZombieCoordinateUpdateInterval: time.Hour,
+		Zombies:                        2,
+		BoardWidth:                     7,
+	},
+		game.ZombieType{Name: "Tank", HitPoints: 2, Movement: game.MovementCharge, Points: 1},
+	)
+
+	alice := &recordingConnection{messages: make(chan string, 16)}
+	aliceConnectionID, err := communicationService.NewConnection(alice)
+	require.NoError(t, err)
+
+	sendTestClusterMessage(t, communicationService, aliceConnectionID, "START alice")
+	splitMessage := strings.Split(readTestClusterMessage(t, alice), " ")
+	require.Len(t, splitMessage, 2)
+	gameID := splitMessage[1]
+
+	sendTestClusterMessage(t, communicationService, aliceConnectionID, "SHOOT 0 0")
+	assert.Equal(t, "BOOM alice 0 Tank:1 Tank_2:1", readTestClusterMessage(t, alice))
+
+	bob := &recordingConnection{messages: make(chan string, 16)}
+	bobConnectionID, err := communicationService.NewConnection(bob)
+	require.NoError(t, err)
+
+	// The zombies haven't moved yet, the joiner still learns where they are
+	sendTestClusterMessage(t, communicationService, bobConnectionID, "JOIN "+gameID+" bob")
+	assert.Equal(t, "WELCOME "+gameID+" bob", readTestClusterMessage(t, bob))
+	assert.Equal(t, "SYNC tick=0 seq=0 width=7 height=30 players=alice:0,bob:0 zombies=Tank:0:0,Tank_2:0:0 blips=", readTestClusterMessage(t, bob))
+
+	sendTestClusterMessage(t, communicationService, aliceConnectionID, "SYNC")
+	assert.Equal(t, "JOINED bob", readTestClusterMessage(t, alice))
+	assert.Equal(t, "ROSTER alice bob", readTestClusterMessage(t, alice))
+	assert.Equal(t, "SYNC tick=0 seq=0 width=7 height=30 players=alice:0,bob:0 zombies=Tank:0:0,Tank_2:0:0 blips=", readTestClusterMessage(t, alice))
+}
+
+func TestLateJoinSyncInFog(t *testing.T) {
+	communicationService, _ := newTestGameNode(t, game.Settings{
+		ZombieCoordinateUpdateInterval: time.Hour,
+		Visibility:                     game.VisibilitySettings{Mode: game.VisibilityApproximate, Range: 5, SectorSize: 10},
+	}, game.ZombieType{Name: "Tank", Movement: game.MovementCharge})
+
+	alice := &recordingConnection{messages: make(chan string, 16)}
+	aliceConnectionID, err := communicationService.NewConnection(alice)
+	require.NoError(t, err)
+
+	sendTestClusterMessage(t, communicationService, aliceConnectionID, "START alice")
+	splitMessage := strings.Split(readTestClusterMessage(t, alice), " ")
+	require.Len(t, splitMessage, 2)
+
+	sendTestClusterMessage(t, communicationService, aliceConnectionID, "SYNC")
+	assert.Equal(t, "SYNC tick=0 seq=0 width=11 height=30 players=alice:0 zombies= blips=Tank:0:0", readTestClusterMessage(t, alice))
+}
+
+func TestDeltaBatchesMovesPerTick(t *testing.T) {
+	// Zombies moving every nanosecond are due on every tick
+	communicationService, _ := newTestGameNode(t, game.Settings{
+		ZombieCoordinateUpdateInterval: time.Hour,
+		Zombies:                        3,
+		WallHealth:                     10,
+	},
+		game.ZombieType{Name: "Runner", MoveInterval: time.Nanosecond, Movement: game.MovementCharge},
+	)
+
+	connection := &recordingConnection{messages: make(chan string, 256)}
+	connectionID, err := communicationService.NewConnection(connection)
+	require.NoError(t, err)
+
+	sendTestClusterMessage(t, communicationService, connectionID, "START alice")
+	require.True(t, strings.HasPrefix(readTestClusterMessage(t, connection), "GAME "))
+
+	for seq, y := range []string{"1", "2", "3"} {
+		splitMessage := strings.Split(readTestClusterMessage(t, connection), " ")
+		require.Len(t, splitMessage, 5)
+		assert.Equal(t, "DELTA", splitMessage[0])
+		assert.Equal(t, "seq="+strconv.Itoa(seq+1), splitMessage[1])
+		assert.ElementsMatch(t, []string{"Runner:0:" + y, "Runner_2:0:" + y, "Runner_3:0:" + y},
+			strings.Split(strings.TrimPrefix(splitMessage[2], "zombies="), ","))
+	}
+}
+
+func TestDeltaReportsGoneZombies(t *testing.T) {
+	communicationService, _ := newTestGameNode(t, game.Settings{
+		ZombieCoordinateUpdateInterval: time.Hour,
+		BoardWidth:                     1,
+		WallHealth:                     2,
+	},
+		game.ZombieType{Name: "Runner", MoveInterval: 5 * time.Millisecond, Movement: game.MovementCharge},
+	)
+
+	connection := &recordingConnection{messages: make(chan string, 256)}
+	connectionID, err := communicationService.NewConnection(connection)
+	require.NoError(t, err)
+
+	sendTestClusterMessage(t, communicationService, connectionID, "START alice")
+	require.True(t, strings.HasPrefix(readTestClusterMessage(t, connection), "GAME "))
+
+	// The zombie breaching the wall despawns and its replacement takes the name
+	var message string
+	for !strings.HasPrefix(message, "WALL ") {
+		message = readTestClusterMessage(t, connection)
+	}
+	assert.Equal(t, "WALL 1", message)
+	assert.Regexp(t, `^DELTA seq=\d+ zombies= blips= gone=Runner$`, readTestClusterMessage(t, connection))
+}
+
+func TestDeltaReportsGoneLanes(t *testing.T) {
+	communicationService := newTestModeNode(t)
+	aliceConnection, _, gameID := startTestModeGame(t, communicationService, game.ModeVersus)
+
+	bobConnection := &recordingConnection{messages: make(chan string, 16)}
+	bobConnectionID, err := communicationService.NewConnection(bobConnection)
+	require.NoError(t, err)
+	joinTestClusterGame(t, communicationService, bobConnectionID, bobConnection, gameID, "bob", aliceConnection)
+
+	carolConnection := &recordingConnection{messages: make(chan string, 16)}
+	carolConnectionID, err := communicationService.NewConnection(carolConnection)
+	require.NoError(t, err)
+	joinTestClusterGame(t, communicationService, carolConnectionID, carolConnection, gameID, "carol", aliceConnection)
+
+	// Carol's lane leaves with her
+	communicationService.HandleDisconnect(context.Background(), carolConnectionID)
+	assert.Equal(t, "LEFT carol", readTestClusterMessage(t, aliceConnection))
+	assert.Equal(t, "ROSTER alice bob", readTestClusterMessage(t, aliceConnection))
+	assert.Equal(t, "DELTA seq=1 zombies= blips= gone=Tank_3", readTestClusterMessage(t, aliceConnection))
+}
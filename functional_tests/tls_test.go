@@ -0,0 +1,140 @@
+package functional_tests
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ScruffyPants/talk-to-zombies/api"
+	"github.com/ScruffyPants/talk-to-zombies/communication"
+)
+
+const testTLSAddress = "localhost:8083"
+
+type staticStatusProvider struct{}
+
+func (staticStatusProvider) GameCount() int {
+	return 0
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+
+	caCert, caKey := newTestCertificate(t, nil, nil, true)
+	serverCert, serverKey := newTestCertificate(t, caCert, caKey, false)
+	clientCert, clientKey := newTestCertificate(t, caCert, caKey, false)
+
+	writeTestPEM(t, filepath.Join(dir, "ca.pem"), "CERTIFICATE", caCert.Raw)
+	writeTestPEM(t, filepath.Join(dir, "server.pem"), "CERTIFICATE", serverCert.Raw)
+	writeTestKey(t, filepath.Join(dir, "server-key.pem"), serverKey)
+
+	router, err := api.NewRouter(api.RouterSettings{
+		Address:       testTLSAddress,
+		WebsocketPath: "/ws",
+		TLS: api.TLSSettings{
+			CertFile:     filepath.Join(dir, "server.pem"),
+			KeyFile:      filepath.Join(dir, "server-key.pem"),
+			ClientCAFile: filepath.Join(dir, "ca.pem"),
+		},
+	}, communication.NewCommunicationService(), staticStatusProvider{})
+	require.NoError(t, err)
+
+	router.Start()
+	defer router.Shutdown(context.Background())
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(caCert)
+
+	newClient := func(certificates []tls.Certificate) *http.Client {
+		return &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:      rootCAs,
+			Certificates: certificates,
+		}}}
+	}
+
+	_, err = newClient(nil).Get("https://" + testTLSAddress + "/healthz")
+	assert.Error(t, err, "connection without a client certificate must be refused")
+
+	resp, err := newClient([]tls.Certificate{{
+		Certificate: [][]byte{clientCert.Raw},
+		PrivateKey:  clientKey,
+	}}).Get("https://" + testTLSAddress + "/healthz")
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestTLSSettingsRefuseDowngrades(t *testing.T) {
+	for name, settings := range map[string]api.TLSSettings{
+		"client CA without certificate": {ClientCAFile: "ca.pem"},
+		"client CA with self-signed":    {ClientCAFile: "ca.pem", SelfSigned: true},
+		"certificate with self-signed":  {CertFile: "server.pem", KeyFile: "server-key.pem", SelfSigned: true},
+	} {
+		_, err := api.NewRouter(api.RouterSettings{
+			Address:       testTLSAddress,
+			WebsocketPath: "/ws",
+			TLS:           settings,
+		}, communication.NewCommunicationService(), staticStatusProvider{})
+		assert.Error(t, err, name)
+	}
+}
+
+func newTestCertificate(t *testing.T, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, isCA bool) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serialNumber, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          serialNumber,
+		Subject:               pkix.Name{CommonName: "localhost"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+
+	certificate, err := x509.ParseCertificate(certDER)
+	require.NoError(t, err)
+
+	return certificate, key
+}
+
+func writeTestKey(t *testing.T, path string, key *ecdsa.PrivateKey) {
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	writeTestPEM(t, path, "EC PRIVATE KEY", keyDER)
+}
+
+func writeTestPEM(t *testing.T, path string, blockType string, bytes []byte) {
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: bytes}), 0600))
+}
@@ -0,0 +1,74 @@
+package functional_tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ScruffyPants/talk-to-zombies/player"
+)
+
+func TestUsernameValidation(t *testing.T) {
+	denyListPath := filepath.Join(t.TempDir(), "denylist.txt")
+	require.NoError(t, os.WriteFile(denyListPath, []byte("# abusive words\nzombiehater\n"), 0600))
+
+	usernameFilter, err := player.NewDenyListFilterFromFile(denyListPath)
+	require.NoError(t, err)
+
+	settings := player.Settings{MinUsernameLength: 2, MaxUsernameLength: 8}
+	playerComponent, err := player.NewPlayerComponent(settings, usernameFilter)
+	require.NoError(t, err)
+
+	for _, username := range []string{"a", "waytoolongname", "bad\x07name", "Z0mbie_H4ter"} {
+		_, err = playerComponent.NewPlayer(player.Player{ConnectionID: username, Username: username, GameID: "game"})
+		assert.ErrorIs(t, err, player.ErrInvalidUsername, username)
+	}
+
+	p, err := playerComponent.NewPlayer(player.Player{ConnectionID: "first", Username: "alice", GameID: "game"})
+	require.NoError(t, err)
+	assert.Equal(t, "alice", p.Username)
+
+	_, err = playerComponent.NewPlayer(player.Player{ConnectionID: "second", Username: "alice", GameID: "game"})
+	assert.ErrorIs(t, err, player.ErrUsernameTaken)
+
+	_, err = playerComponent.NewPlayer(player.Player{ConnectionID: "second", Username: "ALice", GameID: "game"})
+	assert.ErrorIs(t, err, player.ErrUsernameTaken, "usernames differing only in case are taken too")
+
+	_, err = playerComponent.NewPlayer(player.Player{ConnectionID: "third", Username: "alice", GameID: "other"})
+	assert.NoError(t, err, "usernames only have to be unique within a game")
+
+	settings.SuffixDuplicateUsernames = true
+	playerComponent, err = player.NewPlayerComponent(settings, usernameFilter)
+	require.NoError(t, err)
+
+	// suffixed usernames are trimmed to stay within the maximum length
+	for _, expected := range []string{"alicebob", "aliceb_2", "aliceb_3"} {
+		p, err = playerComponent.NewPlayer(player.Player{ConnectionID: expected, Username: "alicebob", GameID: "game"})
+		require.NoError(t, err)
+		assert.Equal(t, expected, p.Username)
+	}
+}
+
+func TestUsernameSettingsValidation(t *testing.T) {
+	for name, settings := range map[string]player.Settings{
+		"no minimum":                {MinUsernameLength: 0, MaxUsernameLength: 8},
+		"maximum below minimum":     {MinUsernameLength: 8, MaxUsernameLength: 4},
+		"maximum within the suffix": {MinUsernameLength: 1, MaxUsernameLength: 4, SuffixDuplicateUsernames: true},
+	} {
+		_, err := player.NewPlayerComponent(settings, nil)
+		assert.Error(t, err, name)
+	}
+
+	// The shortest maximum still leaves room for a letter of the username next to the suffix
+	playerComponent, err := player.NewPlayerComponent(player.Settings{MinUsernameLength: 1, MaxUsernameLength: 5, SuffixDuplicateUsernames: true}, nil)
+	require.NoError(t, err)
+
+	for _, expected := range []string{"alice", "ali_2", "ali_3"} {
+		p, err := playerComponent.NewPlayer(player.Player{ConnectionID: expected, Username: "alice", GameID: "game"})
+		require.NoError(t, err)
+		assert.Equal(t, expected, p.Username)
+	}
+}
@@ -0,0 +1,56 @@
+package functional_tests
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ScruffyPants/talk-to-zombies/game"
+)
+
+func TestVisibilityApproximate(t *testing.T) {
+	communicationService, _ := newTestGameNode(t, game.Settings{
+		ZombieCoordinateUpdateInterval: time.Hour,
+		Visibility:                     game.VisibilitySettings{Mode: game.VisibilityApproximate, Range: 28, SectorSize: 5},
+	},
+		game.ZombieType{Name: "Runner", MoveInterval: 50 * time.Millisecond, Movement: game.MovementCharge},
+	)
+
+	connection := &recordingConnection{messages: make(chan string, 64)}
+	connectionID, err := communicationService.NewConnection(connection)
+	require.NoError(t, err)
+
+	sendTestClusterMessage(t, communicationService, connectionID, "START alice")
+	require.True(t, strings.HasPrefix(readTestClusterMessage(t, connection), "GAME "))
+
+	assert.Equal(t, "DELTA seq=1 zombies= blips=Runner:0:0 gone=", readTestClusterMessage(t, connection))
+	assert.Equal(t, "DELTA seq=2 zombies=Runner:0:2 blips= gone=", readTestClusterMessage(t, connection))
+}
+
+func TestVisibilityScan(t *testing.T) {
+	communicationService, _ := newTestGameNode(t, game.Settings{
+		ZombieCoordinateUpdateInterval: time.Hour,
+		Visibility: game.VisibilitySettings{
+			Mode:         game.VisibilityHidden,
+			ScanCooldown: time.Minute,
+			ScanDuration: time.Second,
+		},
+	}, game.ZombieType{Name: "Tank", Movement: game.MovementCharge})
+
+	connection := &recordingConnection{messages: make(chan string, 16)}
+	connectionID, err := communicationService.NewConnection(connection)
+	require.NoError(t, err)
+
+	sendTestClusterMessage(t, communicationService, connectionID, "START alice")
+	require.True(t, strings.HasPrefix(readTestClusterMessage(t, connection), "GAME "))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "SCAN")
+	assert.Equal(t, "SCAN alice 1000", readTestClusterMessage(t, connection))
+	assert.Equal(t, "DELTA seq=1 zombies=Tank:0:0 blips= gone=", readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "SCAN")
+	assert.Equal(t, "scan is cooling down, ready in 1m0s", readTestClusterMessage(t, connection))
+}
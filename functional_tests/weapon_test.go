@@ -0,0 +1,133 @@
+package functional_tests
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ScruffyPants/talk-to-zombies/game"
+)
+
+func TestWeapons(t *testing.T) {
+	communicationService, _ := newTestGameNode(t, game.Settings{
+		ZombieCoordinateUpdateInterval: time.Hour,
+		Weapons: game.WeaponSettings{
+			GrenadeRadius: 1,
+			GrenadeDelay:  100 * time.Millisecond,
+			GrenadeCount:  1,
+		},
+	},
+		game.ZombieType{Name: "Tank", HitPoints: 5, Movement: game.MovementCharge, Points: 5},
+	)
+
+	connection := &recordingConnection{messages: make(chan string, 16)}
+	connectionID, err := communicationService.NewConnection(connection)
+	require.NoError(t, err)
+
+	sendTestClusterMessage(t, communicationService, connectionID, "START alice")
+	require.True(t, strings.HasPrefix(readTestClusterMessage(t, connection), "GAME "))
+
+	// The zombie waits at (0, 0)
+	sendTestClusterMessage(t, communicationService, connectionID, "SHOOT 0 5")
+	assert.Equal(t, "BOOM alice 0", readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "WEAPON sniper")
+	assert.Equal(t, "WEAPON sniper", readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "SHOOT 0 5")
+	assert.Equal(t, "BOOM alice 0 Tank:4", readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "WEAPON shotgun")
+	assert.Equal(t, "WEAPON shotgun", readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "SHOOT 1 1")
+	assert.Equal(t, "BOOM alice 0 Tank:3", readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "WEAPON grenade")
+	assert.Equal(t, "WEAPON grenade 1", readTestClusterMessage(t, connection))
+
+	thrownAt := time.Now()
+	sendTestClusterMessage(t, communicationService, connectionID, "SHOOT 1 0")
+	assert.Equal(t, "BOOM alice 0 Tank:1", readTestClusterMessage(t, connection))
+	assert.GreaterOrEqual(t, time.Since(thrownAt), 100*time.Millisecond, "grenade must explode after its delay")
+
+	sendTestClusterMessage(t, communicationService, connectionID, "SHOOT 1 0")
+	assert.Equal(t, "out of ammo: grenade", readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "WEAPON bazooka")
+	assert.Equal(t, game.ErrUnknownWeapon.Error(), readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "WEAPON pistol")
+	assert.Equal(t, "WEAPON pistol", readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "SHOOT 0 0")
+	assert.Equal(t, "BOOM alice 5 Tank", readTestClusterMessage(t, connection))
+}
+
+func TestGrenadeFuseWaitsWhilePaused(t *testing.T) {
+	communicationService, _ := newTestGameNode(t, game.Settings{
+		ZombieCoordinateUpdateInterval: time.Hour,
+		Weapons: game.WeaponSettings{
+			GrenadeRadius: 1,
+			GrenadeDelay:  100 * time.Millisecond,
+		},
+	},
+		game.ZombieType{Name: "Tank", HitPoints: 5, Movement: game.MovementCharge, Points: 5},
+	)
+
+	connection := &recordingConnection{messages: make(chan string, 16)}
+	connectionID, err := communicationService.NewConnection(connection)
+	require.NoError(t, err)
+
+	sendTestClusterMessage(t, communicationService, connectionID, "START alice")
+	require.True(t, strings.HasPrefix(readTestClusterMessage(t, connection), "GAME "))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "WEAPON grenade")
+	assert.Equal(t, "WEAPON grenade", readTestClusterMessage(t, connection))
+
+	thrownAt := time.Now()
+	sendTestClusterMessage(t, communicationService, connectionID, "SHOOT 0 0")
+	sendTestClusterMessage(t, communicationService, connectionID, "PAUSE")
+	assert.Equal(t, "PAUSED alice", readTestClusterMessage(t, connection))
+
+	// The fuse would have burnt down twice over, but it stopped with the game
+	time.Sleep(200 * time.Millisecond)
+	assert.Empty(t, connection.messages)
+
+	sendTestClusterMessage(t, communicationService, connectionID, "RESUME")
+	assert.Equal(t, "RESUMED alice", readTestClusterMessage(t, connection))
+	assert.Equal(t, "BOOM alice 0 Tank:3", readTestClusterMessage(t, connection))
+	assert.GreaterOrEqual(t, time.Since(thrownAt), 300*time.Millisecond, "the paused time comes on top of the delay")
+}
+
+func TestGrenadeDefusedWhenGameEnds(t *testing.T) {
+	communicationService, _ := newTestGameNode(t, game.Settings{
+		ZombieCoordinateUpdateInterval: time.Hour,
+		Weapons: game.WeaponSettings{
+			GrenadeRadius: 1,
+			GrenadeDelay:  100 * time.Millisecond,
+		},
+	},
+		game.ZombieType{Name: "Tank", HitPoints: 5, Movement: game.MovementCharge, Points: 5},
+	)
+
+	connection := &recordingConnection{messages: make(chan string, 16)}
+	connectionID, err := communicationService.NewConnection(connection)
+	require.NoError(t, err)
+
+	sendTestClusterMessage(t, communicationService, connectionID, "START alice")
+	require.True(t, strings.HasPrefix(readTestClusterMessage(t, connection), "GAME "))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "WEAPON grenade")
+	assert.Equal(t, "WEAPON grenade", readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "SHOOT 0 0")
+	sendTestClusterMessage(t, communicationService, connectionID, "END")
+	assert.Equal(t, "GAMEOVER ENDED alice", readTestClusterMessage(t, connection))
+
+	time.Sleep(200 * time.Millisecond)
+	assert.Empty(t, connection.messages, "the grenade went out with the game")
+}
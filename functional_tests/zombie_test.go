@@ -0,0 +1,91 @@
+package functional_tests
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ScruffyPants/talk-to-zombies/game"
+)
+
+func TestZombieTypeWounded(t *testing.T) {
+	communicationService, _ := newTestGameNode(t, game.Settings{
+		ZombieCoordinateUpdateInterval: time.Hour,
+	},
+		game.ZombieType{Name: "Tank", HitPoints: 3, Movement: game.MovementCharge, Points: 5},
+	)
+
+	connection := &recordingConnection{messages: make(chan string, 16)}
+	connectionID, err := communicationService.NewConnection(connection)
+	require.NoError(t, err)
+
+	sendTestClusterMessage(t, communicationService, connectionID, "START alice")
+	require.True(t, strings.HasPrefix(readTestClusterMessage(t, connection), "GAME "))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "SHOOT 0 0")
+	assert.Equal(t, "BOOM alice 0 Tank:2", readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "SHOOT 0 0")
+	assert.Equal(t, "BOOM alice 0 Tank:1", readTestClusterMessage(t, connection))
+
+	sendTestClusterMessage(t, communicationService, connectionID, "SHOOT 0 0")
+	assert.Equal(t, "BOOM alice 5 Tank", readTestClusterMessage(t, connection))
+}
+
+func TestZombieTypeRegistryValidation(t *testing.T) {
+	_, err := game.NewZombieTypeRegistry(nil)
+	assert.Error(t, err)
+
+	_, err = game.NewZombieTypeRegistry([]game.ZombieType{{Name: "Tank"}, {Name: "Tank"}})
+	assert.Error(t, err)
+
+	_, err = game.NewZombieTypeRegistry([]game.ZombieType{{Name: "Tank", Movement: "teleport"}})
+	assert.Error(t, err)
+}
+
+func TestZombieOwnMoveInterval(t *testing.T) {
+	// The game interval is an hour, any move comes from the zombie's own schedule
+	communicationService, _ := newTestGameNode(t, game.Settings{
+		ZombieCoordinateUpdateInterval: time.Hour,
+	},
+		game.ZombieType{Name: "Runner", MoveInterval: 50 * time.Millisecond, Movement: game.MovementCharge},
+	)
+
+	connection := &recordingConnection{messages: make(chan string, 64)}
+	connectionID, err := communicationService.NewConnection(connection)
+	require.NoError(t, err)
+
+	sendTestClusterMessage(t, communicationService, connectionID, "START alice")
+	require.True(t, strings.HasPrefix(readTestClusterMessage(t, connection), "GAME "))
+
+	assert.Equal(t, "DELTA seq=1 zombies=Runner:0:1 blips= gone=", readTestClusterMessage(t, connection))
+	assert.Equal(t, "DELTA seq=2 zombies=Runner:0:2 blips= gone=", readTestClusterMessage(t, connection))
+	assert.Equal(t, "DELTA seq=3 zombies=Runner:0:3 blips= gone=", readTestClusterMessage(t, connection))
+}
+
+func TestZombiesStayOnNarrowBoard(t *testing.T) {
+	for _, movement := range []game.MovementPattern{game.MovementZigZag, game.MovementDrift} {
+		communicationService, _ := newTestGameNode(t, game.Settings{
+			ZombieCoordinateUpdateInterval: time.Hour,
+			BoardWidth:                     1,
+		},
+			game.ZombieType{Name: "Runner", MoveInterval: 20 * time.Millisecond, Movement: movement},
+		)
+
+		connection := &recordingConnection{messages: make(chan string, 64)}
+		connectionID, err := communicationService.NewConnection(connection)
+		require.NoError(t, err)
+
+		sendTestClusterMessage(t, communicationService, connectionID, "START alice")
+		require.True(t, strings.HasPrefix(readTestClusterMessage(t, connection), "GAME "))
+
+		// Without room to step sideways every move is a step towards the wall
+		for y := 1; y <= 5; y++ {
+			assert.Equal(t, fmt.Sprintf("DELTA seq=%d zombies=Runner:0:%d blips= gone=", y, y), readTestClusterMessage(t, connection), movement)
+		}
+	}
+}
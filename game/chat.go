@@ -0,0 +1,172 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/ScruffyPants/talk-to-zombies/player"
+)
+
+var (
+	ErrEmptyChatMessage = fmt.Errorf("chat message is empty")
+	ErrChatNotAllowed   = fmt.Errorf("chat message is not allowed")
+	ErrNoTeams          = fmt.Errorf("you are not on a team in this game")
+)
+
+type ChatSettings struct {
+	// MaxLength is the longest chat message in characters
+	MaxLength int
+	// RateLimit is the number of messages a player may send per RatePeriod, 0 means unlimited
+	RateLimit  int
+	RatePeriod time.Duration
+	// Filter refuses abusive messages, every message is allowed if nil
+	Filter TextFilter
+}
+
+// TextFilter decides whether a text is acceptable, player.UsernameFilter implementations fit
+type TextFilter interface {
+	Allowed(text string) bool
+}
+
+// chatFilter refuses messages containing a denied word or phrase. Unlike the username filter it only
+// compares whole words, so a message spanning word boundaries ("as sassy") isn't refused.
+type chatFilter struct {
+	phrases [][]string
+}
+
+var _ TextFilter = (*chatFilter)(nil)
+
+// NewChatFilterFromFile reads the deny list the username filter uses, entries of several words are phrases
+func NewChatFilterFromFile(path string) (*chatFilter, error) {
+	entries, err := player.ReadDenyList(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var phrases [][]string
+	for _, entry := range entries {
+		if phrase := filterWords(entry); len(phrase) > 0 {
+			phrases = append(phrases, phrase)
+		}
+	}
+
+	return &chatFilter{phrases: phrases}, nil
+}
+
+func (f *chatFilter) Allowed(text string) bool {
+	words := filterWords(text)
+
+	for _, phrase := range f.phrases {
+		for start := 0; start+len(phrase) <= len(words); start++ {
+			if matchesPhrase(words[start:start+len(phrase)], phrase) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// filterWords normalizes every word of the text on its own, punctuation around it is ignored
+func filterWords(text string) []string {
+	var words []string
+
+	for _, field := range strings.Fields(text) {
+		word := strings.TrimFunc(player.NormalizeForFilter(field), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+
+		if word != "" {
+			words = append(words, word)
+		}
+	}
+
+	return words
+}
+
+func matchesPhrase(words []string, phrase []string) bool {
+	for j := range phrase {
+		if words[j] != phrase[j] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// say sends the text to every player of the game, or only to the sender's team, skipping players who muted the sender
+func (i *gameInstance) say(ctx context.Context, sender string, text string, teamOnly bool) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	command := "SAY"
+	team := ""
+	if teamOnly {
+		if team = i.mode.team(i, sender); team == "" {
+			return ErrNoTeams
+		}
+		command = "TEAM"
+	}
+
+	if err := i.checkChatLocked(sender, text); err != nil {
+		return err
+	}
+
+	i.broadcastToAllPlayers(ctx, fmt.Sprintf("%s %s %s", command, sender, text), func(p player.Player) bool {
+		if i.mutes[p.Username][sender] {
+			return false
+		}
+
+		return !teamOnly || i.mode.team(i, p.Username) == team
+	})
+
+	return nil
+}
+
+// checkChatLocked refuses empty, long, abusive and too frequent messages, the allowed ones count
+// towards the sender's rate limit
+func (i *gameInstance) checkChatLocked(sender string, text string) error {
+	chat := i.settings.Chat
+
+	if strings.TrimSpace(text) == "" {
+		return ErrEmptyChatMessage
+	}
+
+	if chat.MaxLength > 0 && utf8.RuneCountInString(text) > chat.MaxLength {
+		return fmt.Errorf("chat message is longer than %d characters", chat.MaxLength)
+	}
+
+	if chat.Filter != nil && !chat.Filter.Allowed(text) {
+		return ErrChatNotAllowed
+	}
+
+	// The rate limit goes last, so only messages which are sent count towards it
+	if chat.RateLimit > 0 {
+		var allowed bool
+		if i.chatSent[sender], allowed = allowRate(i.chatSent[sender], time.Now(), chat.RateLimit, chat.RatePeriod); !allowed {
+			return fmt.Errorf("slow down, chat is limited to %d messages per %s", chat.RateLimit, chat.RatePeriod)
+		}
+	}
+
+	return nil
+}
+
+// mute hides the target's chat messages from the player, or shows them again
+func (i *gameInstance) mute(username string, target string, muted bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if !muted {
+		delete(i.mutes[username], target)
+		return
+	}
+
+	if i.mutes[username] == nil {
+		i.mutes[username] = map[string]bool{}
+	}
+	i.mutes[username][target] = true
+}
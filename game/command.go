@@ -0,0 +1,281 @@
+package game
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ScruffyPants/talk-to-zombies/player"
+)
+
+// commandMetrics count the commands handled by name, published on /debug/vars
+var commandMetrics = expvar.NewMap("commands")
+
+type CommandSettings struct {
+	// RateLimit is the number of commands a connection may send per RatePeriod, 0 means unlimited
+	RateLimit  int
+	RatePeriod time.Duration
+}
+
+// commandState is where the sender of a command is, commands list the states they are allowed in
+type commandState uint8
+
+const (
+	// stateLobby is a connection which isn't in a game
+	stateLobby commandState = 1 << iota
+	// stateInGame is a player taking part in a game
+	stateInGame
+	// stateSpectator is a player still watching a game they were eliminated from
+	stateSpectator
+
+	statesInGame = stateInGame | stateSpectator
+	statesAll    = stateLobby | stateInGame | stateSpectator
+)
+
+// argument describes an argument of a command, optional and variadic ones go last
+type argument struct {
+	name     string
+	optional bool
+	// variadic takes every remaining argument, none or more if optional
+	variadic bool
+	integer  bool
+}
+
+type command struct {
+	name      string
+	aliases   []string
+	arguments []argument
+	help      string
+	states    commandState
+
+	handle commandHandler
+	// pipeline is handle wrapped in the registry's middleware
+	pipeline commandHandler
+}
+
+type commandRequest struct {
+	command      *command
+	connectionID string
+	state        commandState
+	// player and instance are only set outside of the lobby
+	player    player.Player
+	instance  *gameInstance
+	arguments []string
+}
+
+type commandHandler func(ctx context.Context, request commandRequest)
+
+// commandMiddleware wraps a handler, f.x. to refuse a request before it reaches the command
+type commandMiddleware func(next commandHandler) commandHandler
+
+// commandRegistry looks commands up by name or alias, case insensitive
+type commandRegistry struct {
+	middleware []commandMiddleware
+	commands   map[string]*command
+	names      []string
+}
+
+func newCommandRegistry(middleware ...commandMiddleware) *commandRegistry {
+	return &commandRegistry{
+		middleware: middleware,
+		commands:   map[string]*command{},
+	}
+}
+
+func (r *commandRegistry) register(cmd command) {
+	cmd.pipeline = cmd.handle
+	for j := len(r.middleware) - 1; j >= 0; j-- {
+		cmd.pipeline = r.middleware[j](cmd.pipeline)
+	}
+
+	for _, name := range append([]string{cmd.name}, cmd.aliases...) {
+		if _, ok := r.commands[strings.ToLower(name)]; ok {
+			panic(fmt.Sprintf("command %s is registered twice", name))
+		}
+
+		r.commands[strings.ToLower(name)] = &cmd
+	}
+
+	r.names = append(r.names, cmd.name)
+	sort.Strings(r.names)
+}
+
+func (r *commandRegistry) lookup(name string) (*command, bool) {
+	cmd, ok := r.commands[strings.ToLower(name)]
+	return cmd, ok
+}
+
+// usage documents the command's arguments, f.x. START {player} [rule=value ...]
+func (cmd *command) usage() string {
+	parts := []string{cmd.name}
+	for _, a := range cmd.arguments {
+		name := a.name
+		if a.variadic {
+			name += " ..."
+		}
+
+		if a.optional {
+			parts = append(parts, "["+name+"]")
+		} else {
+			parts = append(parts, "{"+name+"}")
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// checkArguments validates the number and type of the arguments against the command's schema
+func (cmd *command) checkArguments(arguments []string) error {
+	required, limit := 0, len(cmd.arguments)
+	for _, a := range cmd.arguments {
+		if !a.optional {
+			required++
+		}
+
+		if a.variadic {
+			limit = -1
+		}
+	}
+
+	name := strings.ToLower(cmd.name)
+
+	switch {
+	case limit == 0 && len(arguments) > 0:
+		return fmt.Errorf("%s command takes no arguments", name)
+	case required == limit && len(arguments) != required:
+		return fmt.Errorf("%s command requires %s: %s", name, countArguments(required), cmd.usage())
+	case len(arguments) < required:
+		return fmt.Errorf("%s command requires at least %s: %s", name, countArguments(required), cmd.usage())
+	case limit >= 0 && len(arguments) > limit:
+		return fmt.Errorf("%s command takes at most %s: %s", name, countArguments(limit), cmd.usage())
+	}
+
+	for j, value := range arguments {
+		a := cmd.arguments[len(cmd.arguments)-1]
+		if j < len(cmd.arguments) {
+			a = cmd.arguments[j]
+		}
+
+		if _, err := strconv.Atoi(value); a.integer && err != nil {
+			return fmt.Errorf("%s must be an integer", a.name)
+		}
+	}
+
+	return nil
+}
+
+func countArguments(n int) string {
+	words := []string{"no arguments", "one argument", "two arguments", "three arguments"}
+	if n < len(words) {
+		return words[n]
+	}
+
+	return fmt.Sprintf("%d arguments", n)
+}
+
+// integer returns an argument checked to be an integer
+func (r commandRequest) integer(j int) int {
+	n, _ := strconv.Atoi(r.arguments[j])
+	return n
+}
+
+// help lists the commands allowed in the state, or documents a single command:
+//
+//	COMMANDS {name} ...
+//	HELP {usage} -- {help text}
+func (r *commandRegistry) help(state commandState, name string) (string, error) {
+	if name == "" {
+		var names []string
+		for _, n := range r.names {
+			if cmd, _ := r.lookup(n); cmd.states&state != 0 {
+				names = append(names, n)
+			}
+		}
+
+		return "COMMANDS " + strings.Join(names, " "), nil
+	}
+
+	cmd, ok := r.lookup(name)
+	if !ok {
+		return "", fmt.Errorf("command %s is not supported", name)
+	}
+
+	text := cmd.help
+	if len(cmd.aliases) > 0 {
+		text += fmt.Sprintf(" (also %s)", strings.Join(cmd.aliases, ", "))
+	}
+
+	return fmt.Sprintf("HELP %s -- %s", cmd.usage(), text), nil
+}
+
+// countCommands counts every command by its name in the commands metrics
+func countCommands(next commandHandler) commandHandler {
+	return func(ctx context.Context, request commandRequest) {
+		commandMetrics.Add(request.command.name, 1)
+		next(ctx, request)
+	}
+}
+
+// limitCommands refuses commands from a connection sending more than the rate limit allows
+func (c *component) limitCommands(next commandHandler) commandHandler {
+	settings := c.gameSettings.Commands
+	if settings.RateLimit <= 0 {
+		return next
+	}
+
+	return func(ctx context.Context, request commandRequest) {
+		var allowed bool
+		c.commandsSent.Upsert(request.connectionID, nil, func(_ bool, sent []time.Time, _ []time.Time) []time.Time {
+			var recent []time.Time
+			recent, allowed = allowRate(sent, time.Now(), settings.RateLimit, settings.RatePeriod)
+			return recent
+		})
+
+		if !allowed {
+			commandMetrics.Add("rate_limited", 1)
+			c.sendMessageToConnection(ctx, request.connectionID,
+				fmt.Sprintf("slow down, commands are limited to %d per %s", settings.RateLimit, settings.RatePeriod))
+			return
+		}
+
+		next(ctx, request)
+	}
+}
+
+// authorizeCommands refuses commands which aren't allowed where the sender is
+func (c *component) authorizeCommands(next commandHandler) commandHandler {
+	return func(ctx context.Context, request commandRequest) {
+		if request.command.states&request.state != 0 {
+			next(ctx, request)
+			return
+		}
+
+		commandMetrics.Add("refused", 1)
+
+		switch {
+		case request.state == stateLobby:
+			c.sendMessageToConnection(ctx, request.connectionID, "must be in a game")
+		case request.command.states&statesInGame == 0:
+			c.sendMessageToConnection(ctx, request.connectionID, "already in a game")
+		default:
+			c.sendMessageToConnection(ctx, request.connectionID,
+				fmt.Sprintf("spectators can't use the %s command", strings.ToLower(request.command.name)))
+		}
+	}
+}
+
+// checkCommandArguments refuses commands whose arguments don't fit the schema
+func (c *component) checkCommandArguments(next commandHandler) commandHandler {
+	return func(ctx context.Context, request commandRequest) {
+		if err := request.command.checkArguments(request.arguments); err != nil {
+			c.sendMessageToConnection(ctx, request.connectionID, err.Error())
+			return
+		}
+
+		next(ctx, request)
+	}
+}
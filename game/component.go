@@ -5,66 +5,228 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
-	"strconv"
 	"strings"
+	"time"
 
 	cmap "github.com/orcaman/concurrent-map/v2"
 	"github.com/sirupsen/logrus"
 	"github.com/teris-io/shortid"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/ScruffyPants/talk-to-zombies/communication"
+	"github.com/ScruffyPants/talk-to-zombies/logging"
 	"github.com/ScruffyPants/talk-to-zombies/player"
+	"github.com/ScruffyPants/talk-to-zombies/tracing"
 )
 
-type Component interface{}
+const maxGameIDClaimAttempts = 5
+
+type Component interface {
+	SaveSnapshots() error
+	GameCount() int
+}
+
+// Registry coordinates game ownership between server nodes, games not found
+// locally might be running on a different node
+type Registry interface {
+	ClaimGame(ctx context.Context, gameID string) (bool, error)
+	ReleaseGame(ctx context.Context, gameID string)
+	ForwardToGame(ctx context.Context, gameID string, connectionID string, message communication.Message) (bool, error)
+}
 
 type component struct {
 	playerComponent      player.Component
 	communicationService communication.Service
+	fanOut               *fanOut
+	registry             Registry
+	snapshotStore        SnapshotStore
 
 	gameSettings      Settings
 	gameInstanceStore cmap.ConcurrentMap[string, *gameInstance]
 	shortIDGenerator  *shortid.Shortid
+
+	commands *commandRegistry
+	// commandsSent holds when each connection sent its recent commands, for the rate limit
+	commandsSent cmap.ConcurrentMap[string, []time.Time]
 }
 
 func NewGameComponent(
 	playerComponent player.Component,
 	communicationService communication.Service,
+	registry Registry,
+	snapshotStore SnapshotStore,
 	gameSettings Settings) (*component, error) {
 	shortIDGenerator, err := shortid.New(1, shortid.DefaultABC, rand.Uint64())
 	if err != nil {
 		return nil, err
 	}
 
-	return &component{
+	if gameSettings.ZombieTypes == nil {
+		gameSettings.ZombieTypes, err = NewZombieTypeRegistry(DefaultZombieTypes(gameSettings.ZombieCoordinateUpdateInterval))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if gameSettings.WallHealth <= 0 {
+		gameSettings.WallHealth = 1
+	}
+
+	if gameSettings.Zombies <= 0 {
+		gameSettings.Zombies = 1
+	}
+
+	if gameSettings.BoardWidth <= 0 {
+		gameSettings.BoardWidth = defaultBoardWidth
+	}
+
+	if gameSettings.Endless.Records == nil {
+		gameSettings.Endless.Records = NewMemoryRunStore()
+	}
+
+	c := &component{
 		playerComponent:      playerComponent,
 		communicationService: communicationService,
+		fanOut:               newFanOut(communicationService, gameSettings.FanOut),
+		registry:             registry,
+		snapshotStore:        snapshotStore,
 
 		gameSettings:      gameSettings,
 		gameInstanceStore: cmap.New[*gameInstance](),
 		shortIDGenerator:  shortIDGenerator,
-	}, nil
+		commandsSent:      cmap.New[[]time.Time](),
+	}
+	c.registerCommands()
+
+	if snapshotStore != nil {
+		if err = c.restoreSnapshots(); err != nil {
+			return nil, err
+		}
+
+		c.startSnapshotTicker()
+	}
+
+	return c, nil
+}
+
+func (c *component) GameCount() int {
+	return c.gameInstanceStore.Count()
+}
+
+// SaveSnapshots stores the state of every live game in the snapshot store
+func (c *component) SaveSnapshots() error {
+	if c.snapshotStore == nil {
+		return nil
+	}
+
+	snapshots := make([]Snapshot, 0, c.gameInstanceStore.Count())
+	c.gameInstanceStore.IterCb(func(_ string, instance *gameInstance) {
+		snapshots = append(snapshots, instance.snapshot())
+	})
+
+	return c.snapshotStore.Save(snapshots)
+}
+
+// registerCommands lists every command players can send, each passes through the middleware in order
+func (c *component) registerCommands() {
+	c.commands = newCommandRegistry(countCommands, c.limitCommands, c.authorizeCommands, c.checkCommandArguments)
+
+	target := []argument{{name: "username"}}
+
+	for _, cmd := range []command{
+		{name: "START", handle: c.handleStart, states: stateLobby,
+			arguments: []argument{{name: "player"}, {name: "rule=value", optional: true, variadic: true}},
+			help:      "starts a new game, rules pick the mode and tune it"},
+		{name: "JOIN", handle: c.handleJoin, states: stateLobby,
+			arguments: []argument{{name: "game ID"}, {name: "username"}, {name: "seat token", optional: true}},
+			help:      "joins a running game, a restored game's players rejoin it with their seat token"},
+		{name: "SHOOT", aliases: []string{"FIRE"}, handle: c.handleShoot, states: stateInGame,
+			arguments: []argument{{name: "x", integer: true}, {name: "y", integer: true}},
+			help:      "shoots at a square with the selected weapon"},
+		{name: "WEAPON", handle: c.handleWeapon, states: stateInGame,
+			arguments: []argument{{name: "weapon"}},
+			help:      "selects the weapon for the next shots"},
+		{name: "SCAN", handle: c.handleScan, states: stateInGame,
+			help: "reveals the zombies hidden in the fog for a moment"},
+		{name: "BEST", handle: c.handleBest, states: statesAll,
+			arguments: []argument{{name: "username", optional: true}},
+			help:      "shows the best endless run of a player, yours in a game"},
+		{name: "PLAYERS", aliases: []string{"WHO"}, handle: c.handlePlayers, states: statesInGame,
+			help: "lists the players of the game"},
+		{name: "SYNC", handle: c.handleSync, states: statesInGame,
+			help: "sends the full state of the game"},
+		{name: "SAY", handle: c.handleChat(false), states: statesInGame,
+			arguments: []argument{{name: "message", variadic: true}},
+			help:      "sends a chat message to every player of the game"},
+		{name: "TEAM", handle: c.handleChat(true), states: statesInGame,
+			arguments: []argument{{name: "message", variadic: true}},
+			help:      "sends a chat message to your team"},
+		{name: "MUTE", handle: c.handleMute(true), states: statesInGame,
+			arguments: target, help: "hides the chat messages of a player"},
+		{name: "UNMUTE", handle: c.handleMute(false), states: statesInGame,
+			arguments: target, help: "shows the chat messages of a muted player again"},
+		{name: "PAUSE", handle: c.handleHostCommand, states: statesInGame,
+			help: "pauses the game, host only"},
+		{name: "RESUME", handle: c.handleHostCommand, states: statesInGame,
+			help: "resumes a paused game, host only"},
+		{name: "KICK", handle: c.handleHostCommand, states: statesInGame,
+			arguments: target, help: "removes a player from the game for good, host only"},
+		{name: "TRANSFER", handle: c.handleHostCommand, states: statesInGame,
+			arguments: target, help: "makes another player the host, host only"},
+		{name: "END", handle: c.handleHostCommand, states: statesInGame,
+			help: "ends the game, host only"},
+		{name: "HELP", aliases: []string{"?"}, handle: c.handleHelp, states: statesAll,
+			arguments: []argument{{name: "command", optional: true}},
+			help:      "lists the commands you can use or explains one of them"},
+	} {
+		c.commands.register(cmd)
+	}
 }
 
 func (c *component) OnMessage(ctx context.Context, connectionID string, message communication.Message) {
+	ctx, span := tracing.Tracer().Start(ctx, "game.OnMessage", trace.WithAttributes(
+		tracing.AttributeCommand.String(strings.ToLower(message.Type)),
+	))
+	defer span.End()
+
+	cmd, ok := c.commands.lookup(message.Type)
+	if !ok {
+		c.sendMessageToConnection(ctx, connectionID, fmt.Sprintf("command %s is not supported", message.Type))
+		return
+	}
+
 	playerByConnectionID, err := c.playerComponent.GetPlayerByConnectionID(connectionID)
 	if err != nil && !errors.Is(err, player.ErrPlayerNotFound) {
 		c.sendErrorToConnection(ctx, connectionID, fmt.Errorf("error getting player by connection ID: %w", err))
 		return
 	}
-	isInGame := !errors.Is(err, player.ErrPlayerNotFound)
 
-	switch strings.ToLower(message.Type) {
-	case "start":
-		c.handleStart(ctx, connectionID, isInGame, message.Arguments)
-	case "shoot":
-		c.handleShoot(ctx, playerByConnectionID, isInGame, message.Arguments)
-	case "join":
-		c.handleJoin(ctx, connectionID, isInGame, message.Arguments)
-	default:
-		c.sendMessageToConnection(ctx, connectionID, fmt.Sprintf("command %s is not supported", message.Type))
-		return
+	request := commandRequest{
+		command:      cmd,
+		connectionID: connectionID,
+		state:        stateLobby,
+		arguments:    message.Arguments,
 	}
+
+	if !errors.Is(err, player.ErrPlayerNotFound) {
+		ctx = playerContext(ctx, playerByConnectionID)
+
+		instance, ok := c.gameInstanceStore.Get(playerByConnectionID.GameID)
+		if !ok {
+			// The game is gone, the connection is back in the lobby
+			c.playerComponent.DeletePlayerByConnectionID(connectionID)
+		} else {
+			request.player = playerByConnectionID
+			request.instance = instance
+			request.state = stateInGame
+
+			if instance.isSpectator(playerByConnectionID.Username) {
+				request.state = stateSpectator
+			}
+		}
+	}
+
+	cmd.pipeline(ctx, request)
 }
 
 func (c *component) OnDisconnect(ctx context.Context, connectionID string) {
@@ -74,139 +236,409 @@ func (c *component) OnDisconnect(ctx context.Context, connectionID string) {
 		return
 	}
 
-	defer c.playerComponent.DeletePlayerByConnectionID(connectionID)
+	// The player is removed first, so the game is over once nobody else is left
+	c.playerComponent.DeletePlayerByConnectionID(connectionID)
+	c.commandsSent.Remove(connectionID)
 
 	if len(playerByConnectionID.GameID) == 0 {
 		return
 	}
 
+	ctx = playerContext(ctx, playerByConnectionID)
+
 	gamePlayers, err := c.playerComponent.GetPlayersByGameID(playerByConnectionID.GameID)
 	if err != nil {
 		c.sendErrorToConnection(ctx, connectionID, fmt.Errorf("error getting players by game id: %w", err))
 		return
 	}
 
-	if len(gamePlayers) == 0 {
-		instance, ok := c.gameInstanceStore.Get(playerByConnectionID.GameID)
-		if !ok {
-			return
-		}
+	instance, ok := c.gameInstanceStore.Get(playerByConnectionID.GameID)
+	if !ok {
+		return
+	}
+
+	instance.playerLeft(ctx, playerByConnectionID.Username)
 
+	if len(gamePlayers) == 0 {
 		instance.stop()
-		c.gameInstanceStore.Pop(playerByConnectionID.GameID)
+		c.removeGameInstance(ctx, playerByConnectionID.GameID)
 	}
 }
 
-func (c *component) handleStart(ctx context.Context, connectionID string, isInGame bool, arguments []string) {
-	if isInGame {
-		c.sendMessageToConnection(ctx, connectionID, "already in a game")
-		return
-	}
+func (c *component) handleStart(ctx context.Context, request commandRequest) {
+	connectionID := request.connectionID
 
-	if len(arguments) != 1 {
-		c.sendMessageToConnection(ctx, connectionID, "start command requires one argument: START {player}")
+	rules, err := parseRules(request.arguments[1:], c.gameSettings.Rules)
+	if err != nil {
+		c.sendMessageToConnection(ctx, connectionID, err.Error())
 		return
 	}
 
-	gameID, err := c.shortIDGenerator.Generate()
+	gameID, err := c.claimNewGameID(ctx)
 	if err != nil {
-		logrus.WithContext(ctx).Errorf("error generating short id: %s", err.Error())
+		c.sendErrorToConnection(ctx, connectionID, fmt.Errorf("error creating new game: %w", err))
 		return
 	}
 
 	p := player.Player{
 		ConnectionID: connectionID,
-		Username:     arguments[0],
+		Username:     request.arguments[0],
 		GameID:       gameID,
 	}
+	ctx = playerContext(ctx, p)
 
 	if _, err = c.playerComponent.NewPlayer(p); err != nil {
-		c.sendErrorToConnection(ctx, connectionID, fmt.Errorf("error creating new player: %w", err))
+		c.registry.ReleaseGame(ctx, gameID)
+		c.sendPlayerErrorToConnection(ctx, connectionID, fmt.Errorf("error creating new player: %w", err))
 		return
 	}
 
-	instance := newGameInstance(gameID, c.gameSettings, c.playerComponent, c.communicationService)
+	instance := newGameInstance(gameID, rules, c.gameSettings, c.playerComponent, c.fanOut)
 	c.listenToGameOverSignal(instance)
 
 	c.gameInstanceStore.Set(gameID, instance)
 
-	c.sendMessageToConnection(ctx, connectionID, fmt.Sprintf("GAME %s", gameID))
+	logrus.WithContext(ctx).WithField("rules", strings.Join(rules.options, " ")).Info("game started")
+
+	// Only the picked rules are echoed, keeping the reply to a plain START unchanged
+	c.sendMessageToConnection(ctx, connectionID, strings.TrimSpace(fmt.Sprintf("GAME %s %s", gameID, strings.Join(rules.options, " "))))
+
+	c.sendSeatToken(ctx, connectionID, instance, p.Username)
+
+	instance.playerJoined(ctx, p.Username)
+	instance.setup(ctx)
+}
+
+// sendSeatToken tells the player how to rejoin the game after a restart, only games which are snapshotted
+// are restored so only their players get one
+func (c *component) sendSeatToken(ctx context.Context, connectionID string, instance *gameInstance, username string) {
+	token := instance.seatToken(username)
+
+	if c.snapshotStore != nil {
+		c.sendMessageToConnection(ctx, connectionID, fmt.Sprintf("SEAT %s", token))
+	}
+}
+
+func (c *component) handleShoot(ctx context.Context, request commandRequest) {
+	request.instance.handleUserShot(ctx, request.integer(0), request.integer(1), request.player)
 }
 
-func (c *component) handleShoot(ctx context.Context, playerByConnectionID player.Player, isInGame bool, arguments []string) {
-	if !isInGame {
-		c.sendMessageToConnection(ctx, playerByConnectionID.ConnectionID, "must be in a game")
+func (c *component) handleWeapon(ctx context.Context, request commandRequest) {
+	weaponName, shotsLeft, err := request.instance.selectWeapon(request.player.Username, request.arguments[0])
+	if err != nil {
+		c.sendMessageToConnection(ctx, request.connectionID, err.Error())
 		return
 	}
 
-	instance, ok := c.gameInstanceStore.Get(playerByConnectionID.GameID)
-	if !ok {
-		c.playerComponent.DeletePlayerByConnectionID(playerByConnectionID.ConnectionID)
-		return // TODO: maybe handle this more gracefully
+	// Limited weapons are confirmed with the shots left
+	if shotsLeft >= 0 {
+		c.sendMessageToConnection(ctx, request.connectionID, fmt.Sprintf("WEAPON %s %d", weaponName, shotsLeft))
+		return
+	}
+
+	c.sendMessageToConnection(ctx, request.connectionID, fmt.Sprintf("WEAPON %s", weaponName))
+}
+
+func (c *component) handleScan(ctx context.Context, request commandRequest) {
+	if wait, ok := request.instance.scan(ctx, request.player.Username); !ok {
+		c.sendMessageToConnection(ctx, request.connectionID,
+			fmt.Sprintf("scan is cooling down, ready in %s", wait.Round(time.Second)))
+	}
+}
+
+func (c *component) handleBest(ctx context.Context, request commandRequest) {
+	username := request.player.Username
+	if len(request.arguments) == 1 {
+		username = request.arguments[0]
 	}
 
-	if len(arguments) != 2 {
-		c.sendMessageToConnection(ctx, playerByConnectionID.ConnectionID, "shoot command requires two arguments: SHOOT {x} {y}")
+	if username == "" {
+		c.sendMessageToConnection(ctx, request.connectionID, "best command requires one argument outside of games: BEST [username]")
 		return
 	}
 
-	x, err := strconv.Atoi(arguments[0])
+	run, ok, err := c.gameSettings.Endless.Records.Best(username)
 	if err != nil {
-		c.sendMessageToConnection(ctx, playerByConnectionID.ConnectionID, "coordinate must be an integer")
+		c.sendErrorToConnection(ctx, request.connectionID, fmt.Errorf("error getting best endless run: %w", err))
 		return
 	}
 
-	y, err := strconv.Atoi(arguments[1])
-	if err != nil {
-		c.sendMessageToConnection(ctx, playerByConnectionID.ConnectionID, "coordinate must be an integer")
+	if !ok {
+		c.sendMessageToConnection(ctx, request.connectionID, fmt.Sprintf("no endless runs recorded for %s", username))
 		return
 	}
 
-	instance.handleUserShot(x, y, playerByConnectionID)
+	c.sendMessageToConnection(ctx, request.connectionID, fmt.Sprintf("BEST %s %d %d", run.Username, run.Wave, int(run.Survived.Seconds())))
+}
+
+func (c *component) handlePlayers(ctx context.Context, request commandRequest) {
+	c.sendMessageToConnection(ctx, request.connectionID, request.instance.roster())
+}
+
+func (c *component) handleSync(ctx context.Context, request commandRequest) {
+	c.sendMessageToConnection(ctx, request.connectionID, request.instance.sync())
 }
 
-func (c *component) handleJoin(ctx context.Context, connectionID string, isInGame bool, arguments []string) {
-	if isInGame {
-		c.sendMessageToConnection(ctx, connectionID, "already in game")
+func (c *component) handleChat(teamOnly bool) commandHandler {
+	return func(ctx context.Context, request commandRequest) {
+		if err := request.instance.say(ctx, request.player.Username, strings.Join(request.arguments, " "), teamOnly); err != nil {
+			c.sendMessageToConnection(ctx, request.connectionID, err.Error())
+		}
+	}
+}
+
+func (c *component) handleMute(muted bool) commandHandler {
+	return func(ctx context.Context, request commandRequest) {
+		request.instance.mute(request.player.Username, request.arguments[0], muted)
+
+		c.sendMessageToConnection(ctx, request.connectionID, fmt.Sprintf("%sD %s", request.command.name, request.arguments[0]))
+	}
+}
+
+func (c *component) handleHostCommand(ctx context.Context, request commandRequest) {
+	instance := request.instance
+	username := request.player.Username
+
+	var err error
+	switch request.command.name {
+	case "PAUSE":
+		err = instance.pause(ctx, username)
+	case "RESUME":
+		err = instance.resume(ctx, username)
+	case "KICK":
+		if err = instance.kick(ctx, username, request.arguments[0]); err == nil {
+			c.removePlayerFromGame(ctx, instance, request.arguments[0])
+		}
+	case "TRANSFER":
+		err = instance.transferHost(ctx, username, request.arguments[0])
+	case "END":
+		err = instance.end(ctx, username)
+	}
+
+	if err != nil {
+		c.sendMessageToConnection(ctx, request.connectionID, err.Error())
+	}
+}
+
+func (c *component) handleHelp(ctx context.Context, request commandRequest) {
+	name := ""
+	if len(request.arguments) == 1 {
+		name = request.arguments[0]
+	}
+
+	help, err := c.commands.help(request.state, name)
+	if err != nil {
+		c.sendMessageToConnection(ctx, request.connectionID, err.Error())
 		return
 	}
 
-	if len(arguments) != 2 {
-		c.sendMessageToConnection(ctx, connectionID, "join command requires two arguments: JOIN {game ID} {username}")
+	c.sendMessageToConnection(ctx, request.connectionID, help)
+}
+
+// removePlayerFromGame takes the player out of the game, the connection stays open to start or join another one
+func (c *component) removePlayerFromGame(ctx context.Context, instance *gameInstance, username string) {
+	players, err := c.playerComponent.GetPlayersByGameID(instance.id)
+	if err != nil {
+		logrus.WithContext(ctx).Errorf("error getting players by game id: %s", err.Error())
 		return
 	}
 
+	for _, p := range players {
+		if p.Username == username {
+			c.playerComponent.DeletePlayerByConnectionID(p.ConnectionID)
+			instance.playerLeft(ctx, username)
+			return
+		}
+	}
+}
+
+func (c *component) handleJoin(ctx context.Context, request commandRequest) {
+	connectionID := request.connectionID
+	arguments := request.arguments
+
 	instance, ok := c.gameInstanceStore.Get(arguments[0])
 	if !ok {
-		c.sendMessageToConnection(ctx, connectionID, "game not found")
+		forwarded, err := c.registry.ForwardToGame(ctx, arguments[0], connectionID, communication.Message{
+			Type:      "JOIN",
+			Arguments: arguments,
+		})
+		if err != nil {
+			c.sendErrorToConnection(ctx, connectionID, fmt.Errorf("error looking up game: %w", err))
+			return
+		}
+
+		if !forwarded {
+			c.sendMessageToConnection(ctx, connectionID, "game not found")
+		}
+		return
+	}
+
+	if instance.isKicked(arguments[1]) {
+		c.sendMessageToConnection(ctx, connectionID, ErrPlayerKicked.Error())
 		return
 	}
 
-	if _, err := c.playerComponent.NewPlayer(player.Player{
+	var token string
+	if len(arguments) > 2 {
+		token = arguments[2]
+	}
+
+	if err := instance.checkSeat(arguments[1], token); err != nil {
+		c.sendMessageToConnection(ctx, connectionID, err.Error())
+		return
+	}
+
+	p := player.Player{
 		ConnectionID: connectionID,
 		Username:     arguments[1],
 		GameID:       instance.id,
-	}); err != nil {
-		c.sendErrorToConnection(ctx, connectionID, fmt.Errorf("error creating user instance: %w", err))
+	}
+	ctx = playerContext(ctx, p)
+
+	newPlayer, err := c.playerComponent.NewPlayer(p)
+	if err != nil {
+		c.sendPlayerErrorToConnection(ctx, connectionID, fmt.Errorf("error creating user instance: %w", err))
+		return
+	}
+
+	if newPlayer.Username != p.Username {
+		ctx = playerContext(ctx, newPlayer)
+		c.sendMessageToConnection(ctx, connectionID, fmt.Sprintf("USERNAME %s", newPlayer.Username))
+	}
+
+	logrus.WithContext(ctx).Info("player joined game")
+
+	instance.playerJoined(ctx, newPlayer.Username)
+	instance.welcome(ctx, newPlayer)
+	c.sendSeatToken(ctx, connectionID, instance, newPlayer.Username)
+
+	// Games restored from a snapshot wait for the first player to come back
+	instance.start()
+}
+
+// playerContext adds the player's game and username to the log fields of ctx
+// and the game to the current span
+func playerContext(ctx context.Context, p player.Player) context.Context {
+	trace.SpanFromContext(ctx).SetAttributes(tracing.AttributeGameID.String(p.GameID))
+
+	return logging.WithFields(ctx, logrus.Fields{
+		logging.FieldGameID:   p.GameID,
+		logging.FieldUsername: p.Username,
+	})
+}
+
+// sendPlayerErrorToConnection only logs errors which are not caused by invalid player input
+func (c *component) sendPlayerErrorToConnection(ctx context.Context, connectionID string, err error) {
+	if errors.Is(err, player.ErrInvalidUsername) || errors.Is(err, player.ErrUsernameTaken) {
+		c.sendMessageToConnection(ctx, connectionID, err.Error())
 		return
 	}
+
+	c.sendErrorToConnection(ctx, connectionID, err)
 }
 
 func (c *component) sendErrorToConnection(ctx context.Context, connectionID string, err error) {
 	c.sendMessageToConnection(ctx, connectionID, err.Error())
+	tracing.RecordError(trace.SpanFromContext(ctx), err)
 	logrus.WithContext(ctx).Error(err)
 }
 
+// sendMessageToConnection goes through the fan-out as well, so replies keep their order with the game's messages
 func (c *component) sendMessageToConnection(ctx context.Context, connectionID string, message string) {
-	if err := c.communicationService.SendMessageToConnection(connectionID, []byte(message)); err != nil {
-		logrus.WithContext(ctx).Errorf("error sending a message to connection: %s", err.Error())
-	}
+	c.fanOut.send(ctx, logrus.WithContext(ctx), connectionID, []byte(message))
 }
 
 func (c *component) listenToGameOverSignal(instance *gameInstance) {
 	go func() {
-		for <-instance.gameOverChan {
-			c.gameInstanceStore.Pop(instance.id)
+		<-instance.gameOverChan
+		c.removeGameInstance(context.Background(), instance.id)
+	}()
+}
+
+func (c *component) restoreSnapshots() error {
+	snapshots, err := c.snapshotStore.Load()
+	if err != nil {
+		return fmt.Errorf("error loading game snapshots: %w", err)
+	}
+
+	ctx := context.Background()
+	for _, snapshot := range snapshots {
+		claimed, err := c.registry.ClaimGame(ctx, snapshot.GameID)
+		if err != nil {
+			return fmt.Errorf("error claiming restored game: %w", err)
+		}
+
+		if !claimed {
+			logrus.WithField(logging.FieldGameID, snapshot.GameID).Warn("game is already owned by a different node, not restoring it")
+			continue
+		}
+
+		instance := restoreGameInstance(snapshot, c.gameSettings, c.playerComponent, c.fanOut)
+		c.listenToGameOverSignal(instance)
+		c.gameInstanceStore.Set(instance.id, instance)
+
+		if c.gameSettings.SnapshotRejoinTimeout > 0 {
+			time.AfterFunc(c.gameSettings.SnapshotRejoinTimeout, func() {
+				c.expireRestoredGame(instance)
+			})
+		}
+	}
+
+	if len(snapshots) > 0 {
+		logrus.Infof("Restored %d games from snapshots", c.gameInstanceStore.Count())
+	}
+
+	return nil
+}
+
+// expireRestoredGame removes a restored game nobody rejoined, it would be snapshotted forever otherwise
+func (c *component) expireRestoredGame(instance *gameInstance) {
+	if instance.isStarted() {
+		return
+	}
+
+	logrus.WithField(logging.FieldGameID, instance.id).Info("nobody rejoined the restored game, removing it")
+
+	instance.stop()
+}
+
+func (c *component) startSnapshotTicker() {
+	if c.gameSettings.SnapshotInterval <= 0 {
+		return
+	}
+
+	go func() {
+		for range time.Tick(c.gameSettings.SnapshotInterval) {
+			if err := c.SaveSnapshots(); err != nil {
+				logrus.Errorf("error saving game snapshots: %s", err.Error())
+			}
 		}
 	}()
 }
+
+func (c *component) removeGameInstance(ctx context.Context, gameID string) {
+	if _, ok := c.gameInstanceStore.Pop(gameID); ok {
+		c.registry.ReleaseGame(ctx, gameID)
+	}
+}
+
+// claimNewGameID generates game IDs until one is claimed, IDs are only unique per node
+func (c *component) claimNewGameID(ctx context.Context) (string, error) {
+	for i := 0; i < maxGameIDClaimAttempts; i++ {
+		gameID, err := c.shortIDGenerator.Generate()
+		if err != nil {
+			return "", fmt.Errorf("error generating short id: %w", err)
+		}
+
+		claimed, err := c.registry.ClaimGame(ctx, gameID)
+		if err != nil {
+			return "", fmt.Errorf("error claiming game id: %w", err)
+		}
+
+		if claimed {
+			return gameID, nil
+		}
+	}
+
+	return "", fmt.Errorf("failed to claim a unique game id")
+}
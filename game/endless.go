@@ -0,0 +1,302 @@
+package game
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const ModeEndless = "endless"
+
+// EndlessSettings is the difficulty curve of endless games, every wave has more,
+// faster and eventually tougher zombies than the one before
+type EndlessSettings struct {
+	FirstWaveSize int
+	// WaveGrowth is the number of zombies every wave adds
+	WaveGrowth int
+	// SpeedUp multiplies the move interval of every wave, f.x. 0.9 makes each wave 10% faster
+	SpeedUp         float64
+	MinMoveInterval time.Duration
+	// ToughnessEvery is the number of waves after which zombies get an extra hit point, 0 never
+	ToughnessEvery int
+	// Records keeps the best run of every username
+	Records RunStore
+}
+
+// endlessMode spawns a new, harder wave whenever the last one is cleared, the
+// game goes on until the wall falls
+type endlessMode struct{}
+
+func (endlessMode) name() string {
+	return ModeEndless
+}
+
+func (m endlessMode) setup(ctx context.Context, i *gameInstance) {
+	i.wallHealth = i.settings.WallHealth
+	m.nextWave(ctx, i)
+}
+
+func (endlessMode) nextWave(ctx context.Context, i *gameInstance) {
+	i.wave++
+	i.broadcastToAllPlayers(ctx, fmt.Sprintf("WAVE %d", i.wave))
+
+	curve := i.settings.Endless
+	zombieCount := curve.FirstWaveSize + (i.wave-1)*curve.WaveGrowth
+	if zombieCount < 1 {
+		zombieCount = 1
+	}
+
+	speedUp := 1.0
+	if curve.SpeedUp > 0 {
+		speedUp = math.Pow(curve.SpeedUp, float64(i.wave-1))
+	}
+
+	extraHitPoints := 0
+	if curve.ToughnessEvery > 0 {
+		extraHitPoints = (i.wave - 1) / curve.ToughnessEvery
+	}
+
+	for j := 0; j < zombieCount; j++ {
+		i.spawnZombieWithLocked("", func(z *zombie) {
+			moveInterval := time.Duration(float64(z.moveInterval(i.settings.ZombieCoordinateUpdateInterval)) * speedUp)
+			if moveInterval < curve.MinMoveInterval {
+				moveInterval = curve.MinMoveInterval
+			}
+
+			z.zombieType.MoveInterval = moveInterval
+			z.hitPoints += extraHitPoints
+		})
+	}
+}
+
+func (endlessMode) playerJoined(context.Context, *gameInstance, string) {}
+
+func (endlessMode) playerLeft(context.Context, *gameInstance, string) {}
+
+func (endlessMode) canHit(*zombie, string) bool {
+	return true
+}
+
+func (endlessMode) team(*gameInstance, string) string {
+	return "survivors"
+}
+
+func (m endlessMode) zombieKilled(ctx context.Context, i *gameInstance, _ *zombie, _ string) {
+	if len(i.zombieList) == 0 {
+		m.nextWave(ctx, i)
+	}
+}
+
+func (m endlessMode) zombieReachedWall(ctx context.Context, i *gameInstance, z *zombie) {
+	if i.damageWallLocked(ctx, z) > 0 {
+		if len(i.zombieList) == 0 {
+			m.nextWave(ctx, i)
+		}
+		return
+	}
+
+	survived := i.playedForLocked(time.Now()).Round(time.Second)
+	m.recordRuns(ctx, i, survived)
+
+	i.endLocked(ctx, fmt.Sprintf("GAMEOVER SURVIVED %d %d", i.wave, int(survived.Seconds())))
+}
+
+// recordRuns stores the run for every player still in the game and announces new personal bests
+func (endlessMode) recordRuns(ctx context.Context, i *gameInstance, survived time.Duration) {
+	records := i.settings.Endless.Records
+	if records == nil {
+		return
+	}
+
+	players, err := i.playerComponent.GetPlayersByGameID(i.id)
+	if err != nil {
+		i.logger.Errorf("error trying to get players by game ID: %s", err.Error())
+		return
+	}
+
+	for _, p := range players {
+		run := Run{Username: p.Username, Wave: i.wave, Survived: survived, EndedAt: time.Now()}
+
+		best, err := records.Record(run)
+		if err != nil {
+			i.logger.Errorf("error recording endless run: %s", err.Error())
+			continue
+		}
+
+		if best {
+			i.broadcastToAllPlayers(ctx, fmt.Sprintf("RECORD %s %d %d", p.Username, run.Wave, int(run.Survived.Seconds())))
+		}
+	}
+}
+
+type Run struct {
+	Username string        `json:"username"`
+	Wave     int           `json:"wave"`
+	Survived time.Duration `json:"survived"`
+	EndedAt  time.Time     `json:"ended_at"`
+}
+
+// beats reports whether the run reached a later wave, or the same wave for longer
+func (r Run) beats(other Run) bool {
+	if r.Wave != other.Wave {
+		return r.Wave > other.Wave
+	}
+
+	return r.Survived > other.Survived
+}
+
+// RunStore keeps the best endless run of every username
+type RunStore interface {
+	// Record stores the run if it is the username's best and reports whether it was
+	Record(run Run) (bool, error)
+	Best(username string) (Run, bool, error)
+}
+
+type memoryRunStore struct {
+	mu   sync.Mutex
+	best map[string]Run
+}
+
+var _ RunStore = (*memoryRunStore)(nil)
+
+func NewMemoryRunStore() *memoryRunStore {
+	return &memoryRunStore{best: map[string]Run{}}
+}
+
+func (s *memoryRunStore) Record(run Run) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if best, ok := s.best[run.Username]; ok && !run.beats(best) {
+		return false, nil
+	}
+	s.best[run.Username] = run
+
+	return true, nil
+}
+
+func (s *memoryRunStore) Best(username string) (Run, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	run, ok := s.best[username]
+	return run, ok, nil
+}
+
+// fileRunStore keeps the best runs in memory and writes all of them to the file on every new best,
+// the file is written in the background so games recording runs never wait for the disk
+type fileRunStore struct {
+	path string
+
+	mu     sync.Mutex
+	best   map[string]Run
+	closed bool
+
+	// changed wakes the writer up, a single pending write covers every change before it
+	changed chan struct{}
+	written chan struct{}
+}
+
+var _ RunStore = (*fileRunStore)(nil)
+
+func NewFileRunStore(path string) (*fileRunStore, error) {
+	s := &fileRunStore{
+		path:    path,
+		best:    map[string]Run{},
+		changed: make(chan struct{}, 1),
+		written: make(chan struct{}),
+	}
+
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		var runs []Run
+		if err = json.Unmarshal(data, &runs); err != nil {
+			return nil, err
+		}
+
+		for _, run := range runs {
+			s.best[run.Username] = run
+		}
+	}
+
+	go s.writeChanges()
+
+	return s, nil
+}
+
+func (s *fileRunStore) Record(run Run) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if best, ok := s.best[run.Username]; ok && !run.beats(best) {
+		return false, nil
+	}
+	s.best[run.Username] = run
+
+	if s.closed {
+		return true, nil
+	}
+
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+
+	return true, nil
+}
+
+// writeChanges copies the runs under the lock and writes them after unlocking, until the store is closed
+func (s *fileRunStore) writeChanges() {
+	defer close(s.written)
+
+	for range s.changed {
+		s.mu.Lock()
+		runs := make([]Run, 0, len(s.best))
+		for _, r := range s.best {
+			runs = append(runs, r)
+		}
+		s.mu.Unlock()
+
+		data, err := json.Marshal(runs)
+		if err == nil {
+			err = writeFileAtomic(s.path, data)
+		}
+
+		if err != nil {
+			logrus.Errorf("error writing endless records to '%s': %s", s.path, err.Error())
+		}
+	}
+}
+
+// Close waits for the pending write, runs recorded afterwards are only kept in memory
+func (s *fileRunStore) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.changed)
+	}
+	s.mu.Unlock()
+
+	<-s.written
+
+	return nil
+}
+
+func (s *fileRunStore) Best(username string) (Run, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	run, ok := s.best[username]
+	return run, ok, nil
+}
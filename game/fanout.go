@@ -0,0 +1,99 @@
+package game
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/ScruffyPants/talk-to-zombies/communication"
+)
+
+const defaultFanOutWorkers = 4
+
+type FanOutSettings struct {
+	// Workers is the number of goroutines sending frames to connections
+	Workers int
+}
+
+// fanOut sends frames to connections from a fixed pool of workers rather than a goroutine
+// per message, a connection is always served by the same worker so its frames keep their order
+type fanOut struct {
+	communicationService communication.Service
+	workers              []*fanOutWorker
+}
+
+// fanOutWorker holds the frames its goroutine hasn't sent yet
+type fanOutWorker struct {
+	mu      sync.Mutex
+	ready   *sync.Cond
+	backlog []delivery
+}
+
+type delivery struct {
+	ctx          context.Context
+	logger       *logrus.Entry
+	connectionID string
+	frame        []byte
+}
+
+func newFanOut(communicationService communication.Service, settings FanOutSettings) *fanOut {
+	if settings.Workers <= 0 {
+		settings.Workers = defaultFanOutWorkers
+	}
+
+	f := &fanOut{
+		communicationService: communicationService,
+		workers:              make([]*fanOutWorker, settings.Workers),
+	}
+
+	for j := range f.workers {
+		w := &fanOutWorker{}
+		w.ready = sync.NewCond(&w.mu)
+		f.workers[j] = w
+
+		go f.work(w)
+	}
+
+	return f
+}
+
+// send hands the frame to the connection's worker without waiting, so games can send while holding their lock.
+// A client which can't keep up is dealt with by its connection's queue, not here.
+// The frame is shared between connections and must not be changed afterwards.
+func (f *fanOut) send(ctx context.Context, logger *logrus.Entry, connectionID string, frame []byte) {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(connectionID))
+
+	w := f.workers[hash.Sum32()%uint32(len(f.workers))]
+
+	w.mu.Lock()
+	w.backlog = append(w.backlog, delivery{
+		ctx:          ctx,
+		logger:       logger,
+		connectionID: connectionID,
+		frame:        frame,
+	})
+	w.ready.Signal()
+	w.mu.Unlock()
+}
+
+func (f *fanOut) work(w *fanOutWorker) {
+	for {
+		w.mu.Lock()
+		for len(w.backlog) == 0 {
+			w.ready.Wait()
+		}
+
+		deliveries := w.backlog
+		w.backlog = nil
+		w.mu.Unlock()
+
+		for _, d := range deliveries {
+			if err := f.communicationService.SendMessageToConnection(d.ctx, d.connectionID, d.frame); err != nil {
+				d.logger.Errorf("error sending message to connection: %s", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotHost          = fmt.Errorf("only the host can do that")
+	ErrGamePaused       = fmt.Errorf("game is paused")
+	ErrGameNotPaused    = fmt.Errorf("game is not paused")
+	ErrPlayerNotInGame  = fmt.Errorf("player is not in this game")
+	ErrPlayerKicked     = fmt.Errorf("player was kicked from this game")
+	ErrCannotTargetSelf = fmt.Errorf("host can't do that to themselves")
+)
+
+func (i *gameInstance) checkHostLocked(username string) error {
+	if i.host != username {
+		return ErrNotHost
+	}
+
+	return nil
+}
+
+// pause stops the zombies and grenade fuses until the host resumes the game
+func (i *gameInstance) pause(ctx context.Context, username string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if err := i.checkHostLocked(username); err != nil {
+		return err
+	}
+
+	if i.paused {
+		return ErrGamePaused
+	}
+
+	i.paused = true
+	i.pausedAt = time.Now()
+
+	if i.moveTimer != nil {
+		i.moveTimer.Stop()
+	}
+
+	for f := range i.fuses {
+		f.timer.Stop()
+	}
+
+	i.broadcastToAllPlayers(ctx, fmt.Sprintf("PAUSED %s", username))
+
+	return nil
+}
+
+// resume restarts the zombies and fuses, every one keeps the time it had left until its next move or explosion
+func (i *gameInstance) resume(ctx context.Context, username string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if err := i.checkHostLocked(username); err != nil {
+		return err
+	}
+
+	if !i.paused {
+		return ErrGameNotPaused
+	}
+
+	i.paused = false
+
+	now := time.Now()
+	pausedFor := now.Sub(i.pausedAt)
+	i.pausedTotal += pausedFor
+
+	// Shifting every zombie by the same duration keeps the schedule ordered
+	for _, z := range i.schedule {
+		z.nextMove = z.nextMove.Add(pausedFor)
+	}
+
+	if i.moveTimer != nil {
+		i.moveTimer.Reset(i.untilNextMove(now))
+	}
+
+	// Thrown grenades explode as much later as the game was paused
+	for f := range i.fuses {
+		f.explodesAt = f.explodesAt.Add(pausedFor)
+		f.timer.Reset(f.explodesAt.Sub(now))
+	}
+
+	i.broadcastToAllPlayers(ctx, fmt.Sprintf("RESUMED %s", username))
+
+	return nil
+}
+
+// kick announces the kick to the whole game, the target included, and keeps the
+// player from joining again; removing the player is up to the caller
+func (i *gameInstance) kick(ctx context.Context, username string, target string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if err := i.checkHostLocked(username); err != nil {
+		return err
+	}
+
+	if target == username {
+		return ErrCannotTargetSelf
+	}
+
+	if !i.hasPlayer(target) {
+		return ErrPlayerNotInGame
+	}
+
+	i.kicked[target] = true
+	i.broadcastToAllPlayers(ctx, fmt.Sprintf("KICKED %s", target))
+
+	return nil
+}
+
+func (i *gameInstance) transferHost(ctx context.Context, username string, target string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if err := i.checkHostLocked(username); err != nil {
+		return err
+	}
+
+	if target == username {
+		return ErrCannotTargetSelf
+	}
+
+	if !i.hasPlayer(target) {
+		return ErrPlayerNotInGame
+	}
+
+	i.host = target
+	i.broadcastToAllPlayers(ctx, fmt.Sprintf("HOST %s", target))
+
+	return nil
+}
+
+// end aborts the game
+func (i *gameInstance) end(ctx context.Context, username string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if err := i.checkHostLocked(username); err != nil {
+		return err
+	}
+
+	i.endLocked(ctx, fmt.Sprintf("GAMEOVER ENDED %s", username))
+
+	return nil
+}
+
+func (i *gameInstance) isKicked(username string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for kicked := range i.kicked {
+		if strings.EqualFold(kicked, username) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// passHostLocked hands host duties to the remaining player whose username sorts first
+func (i *gameInstance) passHostLocked(ctx context.Context) {
+	players, err := i.playerComponent.GetPlayersByGameID(i.id)
+	if err != nil {
+		i.logger.Errorf("error trying to get players by game ID: %s", err.Error())
+		return
+	}
+
+	if len(players) == 0 {
+		i.host = ""
+		return
+	}
+
+	sort.Slice(players, func(a, b int) bool {
+		return players[a].Username < players[b].Username
+	})
+
+	i.host = players[0].Username
+	i.broadcastToAllPlayers(ctx, fmt.Sprintf("HOST %s", i.host))
+}
+
+func (i *gameInstance) hasPlayer(username string) bool {
+	players, err := i.playerComponent.GetPlayersByGameID(i.id)
+	if err != nil {
+		i.logger.Errorf("error trying to get players by game ID: %s", err.Error())
+		return false
+	}
+
+	for _, p := range players {
+		if p.Username == username {
+			return true
+		}
+	}
+
+	return false
+}
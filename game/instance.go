@@ -1,132 +1,831 @@
 package game
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
 
-	"github.com/ScruffyPants/talk-to-zombies/communication"
+	"github.com/ScruffyPants/talk-to-zombies/logging"
 	"github.com/ScruffyPants/talk-to-zombies/player"
+	"github.com/ScruffyPants/talk-to-zombies/tracing"
 )
 
 type Settings struct {
 	ZombieCoordinateUpdateInterval time.Duration
+	SnapshotInterval               time.Duration
+	// SnapshotRejoinTimeout is how long a restored game waits for a player to rejoin it, 0 means forever
+	SnapshotRejoinTimeout time.Duration
+	ZombieTypes           *ZombieTypeRegistry
+	Weapons               WeaponSettings
+	Visibility            VisibilitySettings
+	Modes                 ModeSettings
+	Endless               EndlessSettings
+	Chat                  ChatSettings
+	Commands              CommandSettings
+	FanOut                FanOutSettings
+	Rules                 RuleBounds
+	// Zombies is the number of zombies a classic game starts with
+	Zombies    int
+	BoardWidth int
+	// WallHealth is the damage the wall withstands, in versus every player has a wall of their own
+	WallHealth int
 }
 
 type gameInstance struct {
 	id           string
-	zombieList   []zombie
-	zombieTicker *time.Ticker
+	mode         gameMode
+	rules        gameRules
+	zombieList   []*zombie
 	gameOverChan chan bool
+	stopOnce     sync.Once
 	settings     Settings
+	logger       *logrus.Entry
 
-	playerComponent      player.Component
-	communicationService communication.Service
+	// mu guards the game state below and zombieList, which are changed both by
+	// the zombie timer and player commands
+	mu        sync.Mutex
+	started   bool
+	startedAt time.Time
+	// host is the username of the player allowed to pause, kick, transfer host and end the game
+	host     string
+	paused   bool
+	pausedAt time.Time
+	// pausedTotal adds up the finished pauses, paused time doesn't count as time played
+	pausedTotal time.Duration
+	// heldSince is when the snapshot of a restored game was taken, it waits for its players until start
+	heldSince time.Time
+	// fuses are the grenades which haven't exploded yet
+	fuses  map[*fuse]struct{}
+	kicked map[string]bool
+	// seatTokens let the players rejoin after a restart, reservedSeats are the seats of a restored
+	// game waiting for their players to come back with the token
+	seatTokens    map[string]string
+	reservedSeats map[string]string
+	// mutes maps usernames to the players they muted, chatSent to when they recently chatted
+	mutes     map[string]map[string]bool
+	chatSent  map[string][]time.Time
+	schedule  moveSchedule
+	moveTimer *time.Timer
+	tickCount uint64
+	// deltaSeq is the sequence number of the latest DELTA frame, goneZombies are the zombies it hasn't reported gone yet
+	deltaSeq    uint64
+	goneZombies []string
+	scores      map[string]int
+	// kills counts the zombies each player killed, a free-for-all is won on kills rather than points
+	kills    map[string]int
+	loadouts map[string]*loadout
+	// lastScans is when each player last scanned, revealedUntil when the latest scan ends
+	lastScans     map[string]time.Time
+	revealedUntil time.Time
+	// wave is the coop mode's state, laneHealth the health of each versus player's wall
+	wallHealth int
+	wave       int
+	laneHealth map[string]int
+	rng        *rand.Rand
+	rngSource  *rngSource
+
+	playerComponent player.Component
+	fanOut          *fanOut
 }
 
+// newGameInstance creates a game played by the given rules, the rules have already been validated
 func newGameInstance(gameID string,
+	rules gameRules,
+	settings Settings,
+	playerComponent player.Component,
+	fanOut *fanOut) *gameInstance {
+
+	mode, err := newGameMode(rules.mode)
+	if err != nil {
+		mode = classicMode{}
+	}
+
+	instance := &gameInstance{
+		id:            gameID,
+		mode:          mode,
+		rules:         rules,
+		gameOverChan:  make(chan bool),
+		settings:      rules.apply(settings),
+		logger:        logrus.WithField(logging.FieldGameID, gameID),
+		scores:        map[string]int{},
+		kills:         map[string]int{},
+		loadouts:      map[string]*loadout{},
+		lastScans:     map[string]time.Time{},
+		laneHealth:    map[string]int{},
+		kicked:        map[string]bool{},
+		seatTokens:    map[string]string{},
+		reservedSeats: map[string]string{},
+		fuses:         map[*fuse]struct{}{},
+		mutes:         map[string]map[string]bool{},
+		chatSent:      map[string][]time.Time{},
+
+		playerComponent: playerComponent,
+		fanOut:          fanOut,
+	}
+
+	instance.rng, instance.rngSource = newRNG(rand.Uint64())
+
+	return instance
+}
+
+// setup spawns the game mode's zombies and starts the game
+func (i *gameInstance) setup(ctx context.Context) {
+	i.mu.Lock()
+	i.startedAt = time.Now()
+	i.mode.setup(ctx, i)
+	i.mu.Unlock()
+
+	i.start()
+}
+
+// restoreGameInstance recreates a game from a snapshot, the game stays paused
+// until start is called once a player is back
+func restoreGameInstance(snapshot Snapshot,
 	settings Settings,
 	playerComponent player.Component,
-	communicationService communication.Service) *gameInstance {
+	fanOut *fanOut) *gameInstance {
 
 	instance := &gameInstance{
-		id:           gameID,
-		zombieList:   []zombie{newZombie()},
-		gameOverChan: make(chan bool),
-		settings:     settings,
+		id:            snapshot.GameID,
+		gameOverChan:  make(chan bool),
+		settings:      settings,
+		logger:        logrus.WithField(logging.FieldGameID, snapshot.GameID),
+		tickCount:     snapshot.TickCount,
+		startedAt:     snapshot.StartedAt,
+		pausedTotal:   snapshot.PausedFor,
+		heldSince:     snapshot.TakenAt,
+		host:          snapshot.Host,
+		scores:        map[string]int{},
+		kills:         map[string]int{},
+		loadouts:      map[string]*loadout{},
+		lastScans:     map[string]time.Time{},
+		wallHealth:    snapshot.WallHealth,
+		wave:          snapshot.Wave,
+		laneHealth:    map[string]int{},
+		kicked:        map[string]bool{},
+		seatTokens:    map[string]string{},
+		reservedSeats: map[string]string{},
+		fuses:         map[*fuse]struct{}{},
+		mutes:         map[string]map[string]bool{},
+		chatSent:      map[string][]time.Time{},
+
+		playerComponent: playerComponent,
+		fanOut:          fanOut,
+	}
+
+	instance.rng, instance.rngSource = newRNG(snapshot.RNGState)
+
+	mode, err := newGameMode(snapshot.Mode)
+	if err != nil {
+		instance.logger.Warnf("game mode %s no longer exists, restoring the game as classic", snapshot.Mode)
+		mode = classicMode{}
+	}
+	instance.mode = mode
+
+	rules, err := parseRules(snapshot.Rules, settings.Rules)
+	if err != nil {
+		instance.logger.Warnf("game rules %s are no longer allowed, restoring the game with the default rules: %s",
+			strings.Join(snapshot.Rules, " "), err.Error())
+		rules = gameRules{}
+	}
+	instance.rules = rules
+	instance.settings = rules.apply(settings)
+
+	for username, health := range snapshot.LaneHealth {
+		instance.laneHealth[username] = health
+	}
+
+	for _, username := range snapshot.Kicked {
+		instance.kicked[username] = true
+	}
+
+	for username, muted := range snapshot.Mutes {
+		instance.mutes[username] = map[string]bool{}
+		for _, target := range muted {
+			instance.mutes[username][target] = true
+		}
+	}
+
+	for _, z := range snapshot.Zombies {
+		zombieType, ok := instance.settings.ZombieTypes.Get(z.Type)
+		if !ok {
+			instance.logger.Warnf("zombie type %s no longer exists, restoring zombie %s as a random type", z.Type, z.Name)
+			zombieType = instance.settings.ZombieTypes.random(instance.rng)
+		}
 
-		playerComponent:      playerComponent,
-		communicationService: communicationService,
+		instance.zombieList = append(instance.zombieList, &zombie{
+			name:          z.Name,
+			zombieType:    zombieType,
+			x:             z.X,
+			y:             z.Y,
+			hitPoints:     z.HitPoints,
+			direction:     z.Direction,
+			lane:          z.Lane,
+			scheduleIndex: -1,
+		})
+
+		if z.MoveInterval > 0 {
+			instance.zombieList[len(instance.zombieList)-1].zombieType.MoveInterval = z.MoveInterval
+		}
 	}
 
-	instance.startZombieTicker()
+	for _, p := range snapshot.Players {
+		instance.scores[p.Username] = p.Score
+		instance.kills[p.Username] = p.Kills
+
+		if p.SeatToken != "" {
+			instance.reservedSeats[p.Username] = p.SeatToken
+		}
+
+		if p.Weapon == "" {
+			continue
+		}
+
+		w, err := newWeapon(p.Weapon, settings.Weapons)
+		if err != nil {
+			instance.logger.Warnf("weapon %s of %s no longer exists, restoring the default weapon", p.Weapon, p.Username)
+			w, _ = newWeapon(WeaponPistol, settings.Weapons)
+		}
+		instance.loadouts[p.Username] = &loadout{weapon: w, shots: p.Shots}
+	}
 
 	return instance
 }
 
-func (i *gameInstance) startZombieTicker() {
-	i.zombieTicker = time.NewTicker(i.settings.ZombieCoordinateUpdateInterval)
+// start starts moving the zombies, it does nothing if the game is already running
+func (i *gameInstance) start() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.started {
+		return
+	}
+	i.started = true
+
+	now := time.Now()
+
+	// The time a restored game waited for its players doesn't count as played either
+	if !i.heldSince.IsZero() {
+		i.pausedTotal += now.Sub(i.heldSince)
+		i.heldSince = time.Time{}
+	}
+
+	for _, z := range i.zombieList {
+		z.nextMove = now.Add(z.firstMoveDelay(i.rng, i.settings.ZombieCoordinateUpdateInterval))
+		i.schedule.add(z)
+	}
+
+	i.moveTimer = time.NewTimer(i.untilNextMove(now))
 
 	go func() {
 		for {
-			_, ok := <-i.zombieTicker.C
-			if !ok {
+			select {
+			case <-i.moveTimer.C:
+				i.handleZombieUpdate(context.Background())
+			case <-i.gameOverChan:
 				return
 			}
-
-			i.handleZombieUpdate()
 		}
 	}()
 }
 
-func (i *gameInstance) handleUserShot(x, y int, player player.Player) {
-	for j := range i.zombieList {
-		if i.zombieList[j].x == x && i.zombieList[j].y == y {
-			shotHitMessage := fmt.Sprintf("BOOM %s 1 %s", player.Username, i.zombieList[j].name)
-			i.broadcastToAllPlayers(shotHitMessage)
-			i.stop()
+// untilNextMove is how long the move timer should wait for the earliest scheduled zombie
+func (i *gameInstance) untilNextMove(now time.Time) time.Duration {
+	next, ok := i.schedule.next()
+	if !ok {
+		return i.settings.ZombieCoordinateUpdateInterval
+	}
+
+	return next.Sub(now)
+}
+
+// loadout is the weapon a player has picked and the shots fired with each weapon
+type loadout struct {
+	weapon weapon
+	shots  map[string]int
+}
+
+// loadoutLocked returns the player's loadout, players start with a pistol
+func (i *gameInstance) loadoutLocked(username string) *loadout {
+	l, ok := i.loadouts[username]
+	if !ok {
+		pistol, _ := newWeapon(WeaponPistol, i.settings.Weapons)
+		l = &loadout{weapon: pistol}
+		i.loadouts[username] = l
+	}
+
+	if l.shots == nil {
+		l.shots = map[string]int{}
+	}
+
+	return l
+}
+
+// selectWeapon switches the player's weapon and returns how many shots are left with it, -1 if unlimited
+func (i *gameInstance) selectWeapon(username string, name string) (string, int, error) {
+	w, err := newWeapon(name, i.settings.Weapons)
+	if err != nil {
+		return "", 0, err
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	l := i.loadoutLocked(username)
+	l.weapon = w
+
+	if w.ammo == 0 {
+		return w.name, -1, nil
+	}
+
+	return w.name, w.ammo - l.shots[w.name], nil
+}
+
+func (i *gameInstance) handleUserShot(ctx context.Context, x, y int, player player.Player) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	l := i.loadoutLocked(player.Username)
+	w := l.weapon
+
+	if i.paused {
+		i.sendMessageToPlayer(ctx, player, ErrGamePaused.Error())
+		return
+	}
+
+	if w.ammo > 0 && l.shots[w.name] >= w.ammo {
+		i.sendMessageToPlayer(ctx, player, fmt.Sprintf("%s: %s", ErrOutOfAmmo.Error(), w.name))
+		return
+	}
+	l.shots[w.name]++
+
+	if w.delay <= 0 {
+		i.resolveShotLocked(ctx, w, x, y, player)
+		return
+	}
+
+	f := &fuse{explodesAt: time.Now().Add(w.delay), thrower: player.Username, weapon: w.name}
+	i.fuses[f] = struct{}{}
+
+	// The throw's span has ended by the time the grenade explodes, so the explosion gets its own
+	link := trace.LinkFromContext(ctx)
+
+	f.timer = time.AfterFunc(w.delay, func() {
+		ctx, span := tracing.Tracer().Start(context.Background(), "game.Detonate", trace.WithLinks(link))
+		defer span.End()
+		ctx = playerContext(ctx, player)
+
+		i.mu.Lock()
+		defer i.mu.Unlock()
+
+		// The fuse might have burnt down just before the game was paused, resuming sets it again
+		if i.paused {
+			return
+		}
+
+		delete(i.fuses, f)
+
+		if i.isOver() {
+			return
+		}
+
+		i.resolveShotLocked(ctx, w, x, y, player)
+	})
+}
+
+// resolveShotLocked damages every zombie the weapon hits, the BOOM lists killed
+// zombies by name and wounded ones with the hit points they have left
+func (i *gameInstance) resolveShotLocked(ctx context.Context, w weapon, x, y int, player player.Player) {
+	var hits []string
+	points := 0
+	survivors := i.zombieList[:0]
+
+	var killed []*zombie
+
+	for _, z := range i.zombieList {
+		if !w.hits(z.x, z.y, x, y) || !i.mode.canHit(z, player.Username) {
+			survivors = append(survivors, z)
+			continue
+		}
+
+		if !z.hit(w.damage) {
+			hits = append(hits, fmt.Sprintf("%s:%d", z.name, z.hitPoints))
+			survivors = append(survivors, z)
+			continue
+		}
+
+		hits = append(hits, z.name)
+		points += z.zombieType.Points
+		killed = append(killed, z)
+		i.schedule.remove(z)
+	}
+
+	for j := len(survivors); j < len(i.zombieList); j++ {
+		i.zombieList[j] = nil
+	}
+	i.zombieList = survivors
+
+	if len(hits) == 0 {
+		i.sendMessageToPlayer(ctx, player, fmt.Sprintf("BOOM %s 0", player.Username))
+		return
+	}
+
+	i.scores[player.Username] += points
+	i.kills[player.Username] += len(killed)
+
+	shotHitMessage := fmt.Sprintf("BOOM %s %d %s", player.Username, points, strings.Join(hits, " "))
+	i.broadcastToAllPlayers(ctx, shotHitMessage)
+
+	for _, z := range killed {
+		if i.isOver() {
 			return
 		}
+
+		i.mode.zombieKilled(ctx, i, z, player.Username)
 	}
+}
 
-	shotMissedMessage := fmt.Sprintf("BOOM %s 0", player.Username)
-	if err := i.communicationService.SendMessageToConnection(player.ConnectionID, []byte(shotMissedMessage)); err != nil {
-		logrus.Errorf("error sending message to connection: %s", err)
+func (i *gameInstance) isOver() bool {
+	select {
+	case <-i.gameOverChan:
+		return true
+	default:
+		return false
 	}
 }
 
-func (i *gameInstance) handleZombieUpdate() {
-	for j := range i.zombieList {
-		switch rand.Intn(4) {
-		case 0:
-			if i.zombieList[j].x > 0 {
-				i.zombieList[j].x--
-			} else {
-				i.zombieList[j].x++
+// handleZombieUpdate moves every zombie whose move is due and sets the timer for the next one
+func (i *gameInstance) handleZombieUpdate(ctx context.Context) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	// The timer might have fired just before the game was paused
+	if i.paused {
+		return
+	}
+
+	i.tickCount++
+	now := time.Now()
+
+	var moved []*zombie
+	for {
+		z, ok := i.schedule.popDue(now)
+		if !ok {
+			break
+		}
+
+		z.move(i.rng, i.settings.BoardWidth)
+		moved = append(moved, z)
+	}
+
+	// Every move of the tick goes out in a single frame, before whatever the moves lead to
+	i.broadcastDeltaLocked(ctx, moved, now)
+
+	for _, z := range moved {
+		// A zombie reaching the wall might have taken others with it, f.x. a versus lane
+		if z.removed {
+			continue
+		}
+
+		if z.reachedWall() {
+			i.removeZombieLocked(z)
+			i.mode.zombieReachedWall(ctx, i, z)
+
+			if i.isOver() {
+				return
 			}
-		case 1:
-			if i.zombieList[j].x < 10 {
-				i.zombieList[j].x++
-			} else {
-				i.zombieList[j].x--
+			continue
+		}
+
+		// Scheduling from the planned time rather than now keeps the cadence from drifting with timer delays
+		moveInterval := z.moveInterval(i.settings.ZombieCoordinateUpdateInterval)
+		z.nextMove = z.nextMove.Add(moveInterval)
+		if !z.nextMove.After(now) {
+			z.nextMove = now.Add(moveInterval)
+		}
+		i.schedule.add(z)
+	}
+
+	// Zombies despawning at the wall are reported gone in a frame of their own
+	i.broadcastDeltaLocked(ctx, nil, now)
+
+	i.moveTimer.Reset(i.untilNextMove(now))
+}
+
+// playerJoined lets the game mode know about a new player, f.x. to give them a lane
+func (i *gameInstance) playerJoined(ctx context.Context, username string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	// The player starting the game is its host
+	if i.host == "" {
+		i.host = username
+	}
+
+	i.mode.playerJoined(ctx, i, username)
+}
+
+func (i *gameInstance) playerLeft(ctx context.Context, username string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.isOver() {
+		return
+	}
+
+	delete(i.seatTokens, username)
+	i.announceLeaveLocked(ctx, username)
+
+	if username == i.host {
+		i.passHostLocked(ctx)
+	}
+
+	i.mode.playerLeft(ctx, i, username)
+
+	// A versus player leaving takes their lane with them
+	if !i.isOver() {
+		i.broadcastDeltaLocked(ctx, nil, time.Now())
+	}
+}
+
+// spawnZombieLocked adds a zombie of a random type, it starts walking right away if the game is running
+func (i *gameInstance) spawnZombieLocked(lane string) {
+	i.spawnZombieWithLocked(lane, nil)
+}
+
+// spawnZombieWithLocked spawns a zombie which is adjusted before it starts walking, f.x. to make it faster
+func (i *gameInstance) spawnZombieWithLocked(lane string, adjust func(z *zombie)) {
+	z := newZombie(i.rng, i.settings.ZombieTypes.random(i.rng))
+	z.name = i.uniqueZombieNameLocked(z.name)
+	z.lane = lane
+
+	if adjust != nil {
+		adjust(z)
+	}
+
+	i.zombieList = append(i.zombieList, z)
+
+	if !i.started {
+		return
+	}
+
+	now := time.Now()
+	z.nextMove = now.Add(z.firstMoveDelay(i.rng, i.settings.ZombieCoordinateUpdateInterval))
+	i.schedule.add(z)
+
+	if i.moveTimer != nil && !i.paused {
+		i.moveTimer.Reset(i.untilNextMove(now))
+	}
+}
+
+// uniqueZombieNameLocked numbers zombies of the same type, so WALK and BOOM messages can tell them apart
+func (i *gameInstance) uniqueZombieNameLocked(name string) string {
+	taken := map[string]bool{}
+	for _, z := range i.zombieList {
+		taken[z.name] = true
+	}
+
+	uniqueName := name
+	for n := 2; taken[uniqueName]; n++ {
+		uniqueName = fmt.Sprintf("%s_%d", name, n)
+	}
+
+	return uniqueName
+}
+
+// removeZombieLocked takes a zombie off the board without a kill, the next DELTA reports it as gone
+func (i *gameInstance) removeZombieLocked(z *zombie) {
+	i.schedule.remove(z)
+	z.removed = true
+	i.goneZombies = append(i.goneZombies, z.name)
+
+	for j := range i.zombieList {
+		if i.zombieList[j] == z {
+			i.zombieList = append(i.zombieList[:j], i.zombieList[j+1:]...)
+			return
+		}
+	}
+}
+
+func (i *gameInstance) removeLaneLocked(lane string) {
+	for _, z := range append([]*zombie(nil), i.zombieList...) {
+		if z.lane == lane {
+			i.removeZombieLocked(z)
+		}
+	}
+}
+
+// damageWallLocked deals the zombie's damage to the shared wall, announces it and returns the health left
+func (i *gameInstance) damageWallLocked(ctx context.Context, z *zombie) int {
+	i.wallHealth -= z.zombieType.Damage
+	if i.wallHealth < 0 {
+		i.wallHealth = 0
+	}
+
+	i.broadcastToAllPlayers(ctx, fmt.Sprintf("WALL %d", i.wallHealth))
+
+	return i.wallHealth
+}
+
+// endLocked announces the result of the game and stops it
+func (i *gameInstance) endLocked(ctx context.Context, message string) {
+	i.broadcastToAllPlayers(ctx, message)
+	i.stopLocked()
+}
+
+// pausedForLocked is how long the game has been paused or waiting for its players since it started
+func (i *gameInstance) pausedForLocked(now time.Time) time.Duration {
+	pausedFor := i.pausedTotal
+	if i.paused {
+		pausedFor += now.Sub(i.pausedAt)
+	}
+
+	if !i.heldSince.IsZero() {
+		pausedFor += now.Sub(i.heldSince)
+	}
+
+	return pausedFor
+}
+
+// playedForLocked is how long the game has been running, pauses left out
+func (i *gameInstance) playedForLocked(now time.Time) time.Duration {
+	return now.Sub(i.startedAt) - i.pausedForLocked(now)
+}
+
+func (i *gameInstance) isStarted() bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.started
+}
+
+func (i *gameInstance) stop() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.stopLocked()
+}
+
+// stopLocked stops the zombies and defuses the grenades still in the air
+func (i *gameInstance) stopLocked() {
+	i.stopOnce.Do(func() {
+		close(i.gameOverChan)
+
+		if i.moveTimer != nil {
+			i.moveTimer.Stop()
+		}
+
+		for f := range i.fuses {
+			f.timer.Stop()
+			delete(i.fuses, f)
+		}
+	})
+}
+
+func (i *gameInstance) snapshot() Snapshot {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := time.Now()
+
+	snapshot := Snapshot{
+		GameID:     i.id,
+		Mode:       i.mode.name(),
+		TickCount:  i.tickCount,
+		RNGState:   i.rngSource.state,
+		TakenAt:    now,
+		Rules:      i.rules.options,
+		Host:       i.host,
+		StartedAt:  i.startedAt,
+		PausedFor:  i.pausedForLocked(now),
+		WallHealth: i.wallHealth,
+		Wave:       i.wave,
+	}
+
+	if len(i.laneHealth) > 0 {
+		snapshot.LaneHealth = make(map[string]int, len(i.laneHealth))
+		for username, health := range i.laneHealth {
+			snapshot.LaneHealth[username] = health
+		}
+	}
+
+	for username := range i.kicked {
+		snapshot.Kicked = append(snapshot.Kicked, username)
+	}
+	sort.Strings(snapshot.Kicked)
+
+	for username, muted := range i.mutes {
+		for target, ok := range muted {
+			if !ok {
+				continue
 			}
-		default:
-			if i.zombieList[j].y < 30 {
-				i.zombieList[j].y++
-			} else {
-				i.zombieList[j].y--
+
+			if snapshot.Mutes == nil {
+				snapshot.Mutes = map[string][]string{}
 			}
+			snapshot.Mutes[username] = append(snapshot.Mutes[username], target)
+		}
+		sort.Strings(snapshot.Mutes[username])
+	}
+
+	for _, z := range i.zombieList {
+		var moveInterval time.Duration
+		if registryType, ok := i.settings.ZombieTypes.Get(z.zombieType.Name); !ok || registryType.MoveInterval != z.zombieType.MoveInterval {
+			moveInterval = z.zombieType.MoveInterval
 		}
 
-		zombieCoordinatesMessage := fmt.Sprintf("WALK %s %d %d", i.zombieList[j].name, i.zombieList[j].x, i.zombieList[j].y)
-		i.broadcastToAllPlayers(zombieCoordinatesMessage)
+		snapshot.Zombies = append(snapshot.Zombies, ZombieSnapshot{
+			Name:      z.name,
+			Type:      z.zombieType.Name,
+			X:         z.x,
+			Y:         z.y,
+			HitPoints: z.hitPoints,
+			Direction: z.direction,
+			Lane:      z.lane,
+
+			MoveInterval: moveInterval,
+		})
+	}
+
+	scores := make(map[string]int, len(i.scores))
+	for username, score := range i.scores {
+		scores[username] = score
+	}
+
+	players, err := i.playerComponent.GetPlayersByGameID(i.id)
+	if err != nil {
+		i.logger.Errorf("error trying to get players by game ID: %s", err.Error())
+	}
 
-		if i.zombieList[j].y >= 30 {
-			// TODO: broadcast zombie has reached player?
-			i.stop()
+	for _, p := range players {
+		if _, ok := scores[p.Username]; !ok {
+			scores[p.Username] = 0
 		}
 	}
+
+	for username, score := range scores {
+		playerSnapshot := PlayerSnapshot{Username: username, Score: score, Kills: i.kills[username], SeatToken: i.seatTokens[username]}
+		if token, ok := i.reservedSeats[username]; ok {
+			playerSnapshot.SeatToken = token
+		}
+
+		if l, ok := i.loadouts[username]; ok {
+			playerSnapshot.Weapon = l.weapon.name
+			playerSnapshot.Shots = make(map[string]int, len(l.shots))
+			for weaponName, shots := range l.shots {
+				playerSnapshot.Shots[weaponName] = shots
+			}
+
+			// A grenade in the air doesn't survive the restart, so the thrower gets it back
+			for f := range i.fuses {
+				if f.thrower == username {
+					if _, ok := playerSnapshot.Shots[f.weapon]; ok {
+						playerSnapshot.Shots[f.weapon]++
+					}
+				}
+			}
+		}
+
+		snapshot.Players = append(snapshot.Players, playerSnapshot)
+	}
+
+	return snapshot
 }
 
-func (i *gameInstance) stop() {
-	close(i.gameOverChan)
-	i.zombieTicker.Stop()
+func (i *gameInstance) sendMessageToPlayer(ctx context.Context, p player.Player, message string) {
+	i.sendFrameToPlayer(ctx, p, []byte(message))
+}
+
+func (i *gameInstance) sendFrameToPlayer(ctx context.Context, p player.Player, frame []byte) {
+	i.fanOut.send(ctx, i.logger.WithFields(logrus.Fields{
+		logging.FieldConnectionID: p.ConnectionID,
+		logging.FieldUsername:     p.Username,
+	}), p.ConnectionID, frame)
 }
 
-func (i *gameInstance) broadcastToAllPlayers(message string) {
+// broadcastToAllPlayers serializes the message once and hands it to the fan-out, which keeps
+// every player's messages in the order they happen.
+// With include only the players it returns true for get the message.
+func (i *gameInstance) broadcastToAllPlayers(ctx context.Context, message string, include ...func(p player.Player) bool) {
 	players, err := i.playerComponent.GetPlayersByGameID(i.id)
 	if err != nil {
-		logrus.Errorf("error trying to get players by gameID (%s): %s", i.id, err.Error())
+		i.logger.Errorf("error trying to get players by game ID: %s", err.Error())
 		return
 	}
 
+	frame := []byte(message)
 	for _, p := range players {
-		go func() {
-			if err = i.communicationService.SendMessageToConnection(p.ConnectionID, []byte(message)); err != nil {
-				logrus.Errorf("error sending message to connection: %s", err)
-			}
-		}()
+		if len(include) > 0 && !include[0](p) {
+			continue
+		}
+
+		i.sendFrameToPlayer(ctx, p, frame)
 	}
 }
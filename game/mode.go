@@ -0,0 +1,277 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrUnknownGameMode = fmt.Errorf("unknown game mode, choose one of: classic, coop, versus, ffa, endless")
+)
+
+const (
+	ModeClassic = "classic"
+	ModeCoop    = "coop"
+	ModeVersus  = "versus"
+	ModeFFA     = "ffa"
+)
+
+type ModeSettings struct {
+	CoopWaves int
+	// CoopWaveSize is the number of zombies in the first wave, every wave adds as many
+	CoopWaveSize int
+	// FFAKillTarget is the number of kills a player needs to win a free-for-all
+	FFAKillTarget int
+}
+
+// gameMode decides how zombies spawn, who may shoot them and how a game ends,
+// the hooks are called with the game instance locked
+type gameMode interface {
+	name() string
+	setup(ctx context.Context, i *gameInstance)
+	playerJoined(ctx context.Context, i *gameInstance, username string)
+	playerLeft(ctx context.Context, i *gameInstance, username string)
+	canHit(z *zombie, username string) bool
+	// team is the name of the player's team, empty for players without one
+	team(i *gameInstance, username string) string
+	zombieKilled(ctx context.Context, i *gameInstance, z *zombie, username string)
+	zombieReachedWall(ctx context.Context, i *gameInstance, z *zombie)
+}
+
+func newGameMode(name string) (gameMode, error) {
+	switch strings.ToLower(name) {
+	case "", ModeClassic:
+		return classicMode{}, nil
+	case ModeCoop:
+		return coopMode{}, nil
+	case ModeVersus:
+		return versusMode{}, nil
+	case ModeFFA:
+		return ffaMode{}, nil
+	case ModeEndless:
+		return endlessMode{}, nil
+	default:
+		return nil, ErrUnknownGameMode
+	}
+}
+
+// classicMode starts with a single zombie unless the game picks more, the game is won by killing
+// them all and lost once the wall falls, a zombie breaching the wall is replaced by a new one
+type classicMode struct{}
+
+func (classicMode) name() string {
+	return ModeClassic
+}
+
+func (classicMode) setup(_ context.Context, i *gameInstance) {
+	i.wallHealth = i.settings.WallHealth
+
+	for j := 0; j < i.settings.Zombies; j++ {
+		i.spawnZombieLocked("")
+	}
+}
+
+func (classicMode) playerJoined(context.Context, *gameInstance, string) {}
+
+func (classicMode) playerLeft(context.Context, *gameInstance, string) {}
+
+func (classicMode) canHit(*zombie, string) bool {
+	return true
+}
+
+func (classicMode) team(*gameInstance, string) string {
+	return ""
+}
+
+func (classicMode) zombieKilled(ctx context.Context, i *gameInstance, _ *zombie, _ string) {
+	if len(i.zombieList) == 0 {
+		i.endLocked(ctx, "GAMEOVER WIN")
+	}
+}
+
+func (classicMode) zombieReachedWall(ctx context.Context, i *gameInstance, z *zombie) {
+	if i.damageWallLocked(ctx, z) <= 0 {
+		i.endLocked(ctx, "GAMEOVER LOSE")
+		return
+	}
+
+	i.spawnZombieLocked("")
+}
+
+// coopMode has the team defend a shared wall against waves of zombies
+type coopMode struct{}
+
+func (coopMode) name() string {
+	return ModeCoop
+}
+
+func (m coopMode) setup(ctx context.Context, i *gameInstance) {
+	i.wallHealth = i.settings.WallHealth
+	m.nextWave(ctx, i)
+}
+
+func (m coopMode) nextWave(ctx context.Context, i *gameInstance) {
+	i.wave++
+	i.broadcastToAllPlayers(ctx, fmt.Sprintf("WAVE %d", i.wave))
+
+	for j := 0; j < i.wave*i.settings.Modes.CoopWaveSize; j++ {
+		i.spawnZombieLocked("")
+	}
+}
+
+func (coopMode) playerJoined(context.Context, *gameInstance, string) {}
+
+func (coopMode) playerLeft(context.Context, *gameInstance, string) {}
+
+func (coopMode) canHit(*zombie, string) bool {
+	return true
+}
+
+func (coopMode) team(*gameInstance, string) string {
+	return "defenders"
+}
+
+func (m coopMode) zombieKilled(ctx context.Context, i *gameInstance, _ *zombie, _ string) {
+	m.checkWaveCleared(ctx, i)
+}
+
+func (m coopMode) zombieReachedWall(ctx context.Context, i *gameInstance, z *zombie) {
+	if i.damageWallLocked(ctx, z) <= 0 {
+		i.endLocked(ctx, "GAMEOVER LOSE")
+		return
+	}
+
+	m.checkWaveCleared(ctx, i)
+}
+
+func (m coopMode) checkWaveCleared(ctx context.Context, i *gameInstance) {
+	if len(i.zombieList) > 0 {
+		return
+	}
+
+	if i.wave >= i.settings.Modes.CoopWaves {
+		i.endLocked(ctx, "GAMEOVER WIN")
+		return
+	}
+
+	m.nextWave(ctx, i)
+}
+
+// versusMode gives every player a lane and a wall of their own, a player whose
+// wall falls is eliminated and the last one standing wins
+type versusMode struct{}
+
+func (versusMode) name() string {
+	return ModeVersus
+}
+
+func (versusMode) setup(context.Context, *gameInstance) {}
+
+func (versusMode) playerJoined(_ context.Context, i *gameInstance, username string) {
+	if _, ok := i.laneHealth[username]; ok {
+		return
+	}
+
+	i.laneHealth[username] = i.settings.WallHealth
+	i.spawnZombieLocked(username)
+}
+
+// playerLeft forfeits the player's lane, so the others can still win
+func (m versusMode) playerLeft(ctx context.Context, i *gameInstance, username string) {
+	if _, ok := i.laneHealth[username]; !ok {
+		return
+	}
+
+	i.removeLaneLocked(username)
+	i.laneHealth[username] = 0
+
+	m.checkLastSurvivor(ctx, i)
+}
+
+func (versusMode) canHit(z *zombie, username string) bool {
+	return z.lane == username
+}
+
+// team puts the eliminated players together, they can talk while the others play on
+func (versusMode) team(i *gameInstance, username string) string {
+	if health, ok := i.laneHealth[username]; ok && health <= 0 {
+		return "spectators"
+	}
+
+	return ""
+}
+
+func (versusMode) zombieKilled(_ context.Context, i *gameInstance, z *zombie, _ string) {
+	i.spawnZombieLocked(z.lane)
+}
+
+func (m versusMode) zombieReachedWall(ctx context.Context, i *gameInstance, z *zombie) {
+	i.laneHealth[z.lane] -= z.zombieType.Damage
+	if i.laneHealth[z.lane] < 0 {
+		i.laneHealth[z.lane] = 0
+	}
+	i.broadcastToAllPlayers(ctx, fmt.Sprintf("WALL %s %d", z.lane, i.laneHealth[z.lane]))
+
+	if i.laneHealth[z.lane] > 0 {
+		i.spawnZombieLocked(z.lane)
+		return
+	}
+
+	i.removeLaneLocked(z.lane)
+	i.broadcastToAllPlayers(ctx, fmt.Sprintf("ELIMINATED %s", z.lane))
+
+	m.checkLastSurvivor(ctx, i)
+}
+
+func (versusMode) checkLastSurvivor(ctx context.Context, i *gameInstance) {
+	var survivors []string
+	for username, health := range i.laneHealth {
+		if health > 0 {
+			survivors = append(survivors, username)
+		}
+	}
+
+	switch {
+	case len(survivors) == 0:
+		i.endLocked(ctx, "GAMEOVER LOSE")
+	case len(survivors) == 1 && len(i.laneHealth) > 1:
+		i.endLocked(ctx, fmt.Sprintf("GAMEOVER WINNER %s", survivors[0]))
+	}
+}
+
+// ffaMode is a kill race, zombies keep coming and the first player to reach the kill target wins
+type ffaMode struct{}
+
+func (ffaMode) name() string {
+	return ModeFFA
+}
+
+func (ffaMode) setup(context.Context, *gameInstance) {}
+
+func (ffaMode) playerJoined(_ context.Context, i *gameInstance, _ string) {
+	i.spawnZombieLocked("")
+}
+
+func (ffaMode) playerLeft(context.Context, *gameInstance, string) {}
+
+func (ffaMode) canHit(*zombie, string) bool {
+	return true
+}
+
+func (ffaMode) team(*gameInstance, string) string {
+	return ""
+}
+
+func (ffaMode) zombieKilled(ctx context.Context, i *gameInstance, _ *zombie, username string) {
+	if i.kills[username] >= i.settings.Modes.FFAKillTarget {
+		i.endLocked(ctx, fmt.Sprintf("GAMEOVER WINNER %s", username))
+		return
+	}
+
+	i.spawnZombieLocked("")
+}
+
+func (ffaMode) zombieReachedWall(_ context.Context, i *gameInstance, _ *zombie) {
+	i.spawnZombieLocked("")
+}
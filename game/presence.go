@@ -0,0 +1,99 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/ScruffyPants/talk-to-zombies/player"
+)
+
+var ErrSeatReserved = fmt.Errorf("this player's seat is reserved, rejoin with JOIN {game ID} {username} {seat token}")
+
+// checkSeat refuses to seat anyone under the name of a restored player who didn't rejoin yet,
+// unless they show the player's seat token
+func (i *gameInstance) checkSeat(username string, token string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for seated, reserved := range i.reservedSeats {
+		if strings.EqualFold(seated, username) && reserved != token {
+			return ErrSeatReserved
+		}
+	}
+
+	return nil
+}
+
+// seatToken is what the player rejoins the game with after a restart,
+// a restored player keeps the token they rejoined with
+func (i *gameInstance) seatToken(username string) string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	token, ok := i.reservedSeats[username]
+	if ok {
+		delete(i.reservedSeats, username)
+	} else {
+		token = uuid.NewString()
+	}
+	i.seatTokens[username] = token
+
+	return token
+}
+
+// welcome acknowledges the JOIN with the state of the game and tells the other players who joined
+func (i *gameInstance) welcome(ctx context.Context, joined player.Player) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	i.sendMessageToPlayer(ctx, joined, fmt.Sprintf("WELCOME %s %s", i.id, joined.Username))
+	i.sendMessageToPlayer(ctx, joined, i.syncMessageLocked())
+
+	others := func(p player.Player) bool {
+		return p.ConnectionID != joined.ConnectionID
+	}
+	i.broadcastToAllPlayers(ctx, fmt.Sprintf("JOINED %s", joined.Username), others)
+	i.broadcastToAllPlayers(ctx, i.rosterMessageLocked(), others)
+}
+
+// announceLeaveLocked tells the remaining players who left
+func (i *gameInstance) announceLeaveLocked(ctx context.Context, username string) {
+	i.broadcastToAllPlayers(ctx, fmt.Sprintf("LEFT %s", username))
+	i.broadcastToAllPlayers(ctx, i.rosterMessageLocked())
+}
+
+func (i *gameInstance) roster() string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.rosterMessageLocked()
+}
+
+// rosterMessageLocked lists the usernames in the game in alphabetical order
+func (i *gameInstance) rosterMessageLocked() string {
+	players, err := i.playerComponent.GetPlayersByGameID(i.id)
+	if err != nil {
+		i.logger.Errorf("error trying to get players by game ID: %s", err.Error())
+	}
+
+	usernames := make([]string, 0, len(players))
+	for _, p := range players {
+		usernames = append(usernames, p.Username)
+	}
+	sort.Strings(usernames)
+
+	return strings.TrimSpace("ROSTER " + strings.Join(usernames, " "))
+}
+
+// isSpectator reports whether the player was eliminated and is only watching the game
+func (i *gameInstance) isSpectator(username string) bool {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	health, ok := i.laneHealth[username]
+	return ok && health <= 0
+}
@@ -0,0 +1,23 @@
+package game
+
+import "time"
+
+// allowRate is the sliding window rate limit of chat and commands: sent holds when the earlier events
+// happened, another one is allowed if fewer than limit of them are within the period. The events still
+// within the period are returned, with now added if it was allowed
+func allowRate(sent []time.Time, now time.Time, limit int, period time.Duration) ([]time.Time, bool) {
+	since := now.Add(-period)
+
+	recent := sent[:0]
+	for _, sentAt := range sent {
+		if sentAt.After(since) {
+			recent = append(recent, sentAt)
+		}
+	}
+
+	if len(recent) >= limit {
+		return recent, false
+	}
+
+	return append(recent, now), true
+}
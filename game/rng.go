@@ -0,0 +1,34 @@
+package game
+
+import "math/rand"
+
+// rngSource is a splitmix64 generator, unlike the math/rand sources its whole
+// state is a single integer which can be stored in a snapshot and restored
+type rngSource struct {
+	state uint64
+}
+
+var _ rand.Source64 = (*rngSource)(nil)
+
+func newRNG(state uint64) (*rand.Rand, *rngSource) {
+	source := &rngSource{state: state}
+	return rand.New(source), source
+}
+
+func (s *rngSource) Uint64() uint64 {
+	s.state += 0x9e3779b97f4a7c15
+
+	z := s.state
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+
+	return z ^ (z >> 31)
+}
+
+func (s *rngSource) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+func (s *rngSource) Seed(seed int64) {
+	s.state = uint64(seed)
+}
@@ -0,0 +1,138 @@
+package game
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidRule = fmt.Errorf("invalid game rule")
+)
+
+// RuleBounds limit the rules players may pick for their own games
+type RuleBounds struct {
+	MinZombies    int
+	MaxZombies    int
+	MinInterval   time.Duration
+	MaxInterval   time.Duration
+	MinWidth      int
+	MaxWidth      int
+	MinWallHealth int
+	MaxWallHealth int
+}
+
+// gameRules are the options a game was started with, only the picked ones are set
+type gameRules struct {
+	mode     string
+	zombies  int
+	interval time.Duration
+	width    int
+	wall     int
+
+	// options are the picked rules in their normalized key=value form, in the order they were given
+	options []string
+}
+
+// parseRules parses START options, a bare word is the game mode for compatibility with `START {player} {mode}`
+func parseRules(arguments []string, bounds RuleBounds) (gameRules, error) {
+	var rules gameRules
+	seen := map[string]bool{}
+
+	for _, argument := range arguments {
+		key, value, ok := strings.Cut(argument, "=")
+		if !ok {
+			key, value = "mode", argument
+		}
+		key = strings.ToLower(key)
+
+		if seen[key] {
+			return gameRules{}, fmt.Errorf("%w: %s is given more than once", ErrInvalidRule, key)
+		}
+		seen[key] = true
+
+		var err error
+		switch key {
+		case "mode":
+			var mode gameMode
+			if mode, err = newGameMode(value); err != nil {
+				return gameRules{}, err
+			}
+			rules.mode = mode.name()
+			value = rules.mode
+		case "zombies":
+			rules.zombies, err = parseIntRule(key, value, bounds.MinZombies, bounds.MaxZombies)
+		case "interval":
+			rules.interval, err = parseDurationRule(key, value, bounds.MinInterval, bounds.MaxInterval)
+			value = rules.interval.String()
+		case "width":
+			rules.width, err = parseIntRule(key, value, bounds.MinWidth, bounds.MaxWidth)
+		case "wall":
+			rules.wall, err = parseIntRule(key, value, bounds.MinWallHealth, bounds.MaxWallHealth)
+		default:
+			return gameRules{}, fmt.Errorf("%w: unknown rule %s, choose from: mode, zombies, interval, width, wall", ErrInvalidRule, key)
+		}
+		if err != nil {
+			return gameRules{}, err
+		}
+
+		rules.options = append(rules.options, key+"="+value)
+	}
+
+	if rules.zombies > 0 && (rules.mode == ModeVersus || rules.mode == ModeFFA) {
+		return gameRules{}, fmt.Errorf("%w: zombies can't be picked in %s games", ErrInvalidRule, rules.mode)
+	}
+
+	return rules, nil
+}
+
+func parseIntRule(key string, value string, min int, max int) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("%w: %s must be a number between %d and %d", ErrInvalidRule, key, min, max)
+	}
+
+	return n, nil
+}
+
+func parseDurationRule(key string, value string, min time.Duration, max time.Duration) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil || d < min || d > max {
+		return 0, fmt.Errorf("%w: %s must be a duration between %s and %s", ErrInvalidRule, key, min, max)
+	}
+
+	return d, nil
+}
+
+// apply returns the game's own copy of the settings with the picked rules in place
+func (r gameRules) apply(settings Settings) Settings {
+	if r.interval > 0 {
+		if settings.ZombieTypes != nil {
+			settings.ZombieTypes = settings.ZombieTypes.scaled(settings.ZombieCoordinateUpdateInterval, r.interval)
+		}
+		settings.ZombieCoordinateUpdateInterval = r.interval
+	}
+
+	if r.width > 0 {
+		settings.BoardWidth = r.width
+	}
+
+	if r.wall > 0 {
+		settings.WallHealth = r.wall
+	}
+
+	// The zombies rule is the size of the board's first batch of zombies in every mode which has one
+	if r.zombies > 0 {
+		switch r.mode {
+		case ModeCoop:
+			settings.Modes.CoopWaveSize = r.zombies
+		case ModeEndless:
+			settings.Endless.FirstWaveSize = r.zombies
+		default:
+			settings.Zombies = r.zombies
+		}
+	}
+
+	return settings
+}
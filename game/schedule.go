@@ -0,0 +1,73 @@
+package game
+
+import (
+	"container/heap"
+	"time"
+)
+
+// moveSchedule orders zombies by their next move, so a game needs a single timer
+// set to the earliest move no matter how many zombies are walking
+type moveSchedule []*zombie
+
+var _ heap.Interface = (*moveSchedule)(nil)
+
+func (s moveSchedule) Len() int {
+	return len(s)
+}
+
+func (s moveSchedule) Less(i, j int) bool {
+	return s[i].nextMove.Before(s[j].nextMove)
+}
+
+func (s moveSchedule) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+	s[i].scheduleIndex = i
+	s[j].scheduleIndex = j
+}
+
+func (s *moveSchedule) Push(x any) {
+	z := x.(*zombie)
+	z.scheduleIndex = len(*s)
+	*s = append(*s, z)
+}
+
+func (s *moveSchedule) Pop() any {
+	old := *s
+	z := old[len(old)-1]
+	old[len(old)-1] = nil
+	z.scheduleIndex = -1
+	*s = old[:len(old)-1]
+
+	return z
+}
+
+func (s *moveSchedule) add(z *zombie) {
+	heap.Push(s, z)
+}
+
+// remove takes the zombie off the schedule, it does nothing for zombies which aren't scheduled
+func (s *moveSchedule) remove(z *zombie) {
+	if z.scheduleIndex < 0 || z.scheduleIndex >= len(*s) || (*s)[z.scheduleIndex] != z {
+		return
+	}
+
+	heap.Remove(s, z.scheduleIndex)
+}
+
+// popDue takes the next zombie which should have moved by now off the schedule
+func (s *moveSchedule) popDue(now time.Time) (*zombie, bool) {
+	if len(*s) == 0 || (*s)[0].nextMove.After(now) {
+		return nil, false
+	}
+
+	return heap.Pop(s).(*zombie), true
+}
+
+// next returns the time of the earliest move
+func (s moveSchedule) next() (time.Time, bool) {
+	if len(s) == 0 {
+		return time.Time{}, false
+	}
+
+	return s[0].nextMove, true
+}
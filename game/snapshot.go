@@ -0,0 +1,116 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type Snapshot struct {
+	GameID    string           `json:"game_id"`
+	Mode      string           `json:"mode"`
+	Zombies   []ZombieSnapshot `json:"zombies"`
+	Players   []PlayerSnapshot `json:"players"`
+	TickCount uint64           `json:"tick_count"`
+	RNGState  uint64           `json:"rng_state"`
+	TakenAt   time.Time        `json:"taken_at"`
+
+	Rules     []string  `json:"rules,omitempty"`
+	Host      string    `json:"host,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	// PausedFor is how long the game was paused before the snapshot was taken
+	PausedFor  time.Duration  `json:"paused_for,omitempty"`
+	WallHealth int            `json:"wall_health,omitempty"`
+	Wave       int            `json:"wave,omitempty"`
+	LaneHealth map[string]int `json:"lane_health,omitempty"`
+	Kicked     []string       `json:"kicked,omitempty"`
+	// Mutes maps usernames to the players they muted
+	Mutes map[string][]string `json:"mutes,omitempty"`
+	// Grenades still in the air aren't kept, their throwers get them back instead
+}
+
+type ZombieSnapshot struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	X         int    `json:"x"`
+	Y         int    `json:"y"`
+	HitPoints int    `json:"hit_points"`
+	Direction int    `json:"direction"`
+	Lane      string `json:"lane,omitempty"`
+	// MoveInterval is only set for zombies moving faster or slower than their type
+	MoveInterval time.Duration `json:"move_interval,omitempty"`
+}
+
+type PlayerSnapshot struct {
+	Username string         `json:"username"`
+	Score    int            `json:"score"`
+	Kills    int            `json:"kills,omitempty"`
+	Weapon   string         `json:"weapon,omitempty"`
+	Shots    map[string]int `json:"shots,omitempty"`
+	// SeatToken is what the player rejoins the restored game with, nobody else can take their seat
+	SeatToken string `json:"seat_token,omitempty"`
+}
+
+// SnapshotStore persists the state of all live games, each Save replaces the previous one
+type SnapshotStore interface {
+	Save(snapshots []Snapshot) error
+	Load() ([]Snapshot, error)
+}
+
+type fileSnapshotStore struct {
+	path string
+}
+
+var _ SnapshotStore = (*fileSnapshotStore)(nil)
+
+func NewFileSnapshotStore(path string) *fileSnapshotStore {
+	return &fileSnapshotStore{path: path}
+}
+
+func (s *fileSnapshotStore) Save(snapshots []Snapshot) error {
+	data, err := json.Marshal(snapshots)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(s.path, data)
+}
+
+// writeFileAtomic writes to a temporary file first so a crash mid-write never leaves a truncated file
+func writeFileAtomic(path string, data []byte) error {
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err = tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		return err
+	}
+
+	if err = tmpFile.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), path)
+}
+
+func (s *fileSnapshotStore) Load() ([]Snapshot, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var snapshots []Snapshot
+	if err = json.Unmarshal(data, &snapshots); err != nil {
+		return nil, err
+	}
+
+	return snapshots, nil
+}
@@ -0,0 +1,95 @@
+package game
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+func (i *gameInstance) sync() string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return i.syncMessageLocked()
+}
+
+// syncMessageLocked is the state a player needs to catch up with the game, f.x. after joining
+// mid-game, every DELTA with a later sequence number is a change to this state:
+//
+//	SYNC tick={n} seq={n} width={w} height={h} players={username}:{score},... zombies={position},... blips={position},...
+func (i *gameInstance) syncMessageLocked() string {
+	players, err := i.playerComponent.GetPlayersByGameID(i.id)
+	if err != nil {
+		i.logger.Errorf("error trying to get players by game ID: %s", err.Error())
+	}
+
+	sort.Slice(players, func(a, b int) bool {
+		return players[a].Username < players[b].Username
+	})
+
+	scores := make([]string, 0, len(players))
+	for _, p := range players {
+		scores = append(scores, fmt.Sprintf("%s:%d", p.Username, i.scores[p.Username]))
+	}
+
+	positions, _ := i.positionsLocked(i.zombieList, time.Now())
+
+	return fmt.Sprintf("SYNC tick=%d seq=%d width=%d height=%d players=%s %s",
+		i.tickCount, i.deltaSeq, i.settings.BoardWidth, wallY, strings.Join(scores, ","), positions)
+}
+
+// broadcastDeltaLocked sends the new positions of the zombies and the zombies gone since the last frame as a
+// single frame, nothing if all of them are hidden and none are gone:
+//
+//	DELTA seq={n} zombies={position},... blips={position},... gone={name},...
+func (i *gameInstance) broadcastDeltaLocked(ctx context.Context, zombies []*zombie, now time.Time) {
+	positions, reported := i.positionsLocked(zombies, now)
+	if reported == 0 && len(i.goneZombies) == 0 {
+		return
+	}
+
+	i.deltaSeq++
+	i.broadcastToAllPlayers(ctx, fmt.Sprintf("DELTA seq=%d %s gone=%s", i.deltaSeq, positions, strings.Join(i.goneZombies, ",")))
+	i.goneZombies = nil
+}
+
+// IsPositionUpdate reports whether the frame is a DELTA only moving zombies, the only message a client
+// can do without as the next DELTA or a SYNC makes up for it. A DELTA reporting zombies gone isn't,
+// no later DELTA repeats that.
+func IsPositionUpdate(frame []byte) bool {
+	return bytes.HasPrefix(frame, []byte("DELTA ")) && bytes.HasSuffix(frame, []byte(" gone="))
+}
+
+// positionsLocked lists the zombies the players may know of, exact positions as zombies
+// and approximate ones as blips, hidden zombies are left out and not counted in reported
+func (i *gameInstance) positionsLocked(zombies []*zombie, now time.Time) (positions string, reported int) {
+	var exact, approximate []string
+	for _, z := range zombies {
+		x, y, isExact, ok := i.zombiePositionLocked(z, now)
+
+		switch {
+		case !ok:
+		case isExact:
+			exact = append(exact, formatPosition(z, x, y))
+		default:
+			approximate = append(approximate, formatPosition(z, x, y))
+		}
+	}
+
+	positions = fmt.Sprintf("zombies=%s blips=%s", strings.Join(exact, ","), strings.Join(approximate, ","))
+
+	return positions, len(exact) + len(approximate)
+}
+
+// formatPosition writes a zombie's position as {name}:{x}:{y}, followed by :{username} for
+// zombies in a versus lane, which only that player can shoot
+func formatPosition(z *zombie, x, y int) string {
+	if z.lane == "" {
+		return fmt.Sprintf("%s:%d:%d", z.name, x, y)
+	}
+
+	return fmt.Sprintf("%s:%d:%d:%s", z.name, x, y, z.lane)
+}
@@ -0,0 +1,87 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type VisibilityMode string
+
+const (
+	// VisibilityOff reports the exact position of every zombie
+	VisibilityOff VisibilityMode = "off"
+	// VisibilityApproximate reports zombies out of range as a BLIP in a coarse sector
+	VisibilityApproximate VisibilityMode = "approximate"
+	// VisibilityHidden doesn't report zombies out of range at all
+	VisibilityHidden VisibilityMode = "hidden"
+)
+
+type VisibilitySettings struct {
+	Mode VisibilityMode
+	// Range is the distance from the wall within which zombies are always visible
+	Range int
+	// SectorSize is the size of the squares approximate positions are rounded down to
+	SectorSize int
+	// ScanCooldown is how long a player waits between scans
+	ScanCooldown time.Duration
+	// ScanDuration is how long a scan reveals every zombie for
+	ScanDuration time.Duration
+}
+
+func ParseVisibilityMode(mode string) (VisibilityMode, error) {
+	switch VisibilityMode(mode) {
+	case "", VisibilityOff:
+		return VisibilityOff, nil
+	case VisibilityApproximate, VisibilityHidden:
+		return VisibilityMode(mode), nil
+	default:
+		return "", fmt.Errorf("unsupported visibility mode %s", mode)
+	}
+}
+
+// zombiePositionLocked returns the position the players may know of and whether it is exact,
+// ok is false if the zombie is hidden
+func (i *gameInstance) zombiePositionLocked(z *zombie, now time.Time) (x int, y int, exact bool, ok bool) {
+	visibility := i.settings.Visibility
+
+	fogged := visibility.Mode == VisibilityApproximate || visibility.Mode == VisibilityHidden
+	if !fogged || wallY-z.y <= visibility.Range || now.Before(i.revealedUntil) {
+		return z.x, z.y, true, true
+	}
+
+	if visibility.Mode == VisibilityHidden {
+		return 0, 0, false, false
+	}
+
+	sectorSize := visibility.SectorSize
+	if sectorSize <= 0 {
+		sectorSize = 1
+	}
+
+	return z.x / sectorSize * sectorSize, z.y / sectorSize * sectorSize, false, true
+}
+
+// scan reveals every zombie for the scan duration unless the player scanned too recently,
+// it returns how long the player still has to wait otherwise
+func (i *gameInstance) scan(ctx context.Context, username string) (time.Duration, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := time.Now()
+
+	if readyAt := i.lastScans[username].Add(i.settings.Visibility.ScanCooldown); now.Before(readyAt) {
+		return readyAt.Sub(now), false
+	}
+	i.lastScans[username] = now
+
+	revealedUntil := now.Add(i.settings.Visibility.ScanDuration)
+	if revealedUntil.After(i.revealedUntil) {
+		i.revealedUntil = revealedUntil
+	}
+
+	i.broadcastToAllPlayers(ctx, fmt.Sprintf("SCAN %s %d", username, i.settings.Visibility.ScanDuration.Milliseconds()))
+	i.broadcastDeltaLocked(ctx, i.zombieList, now)
+
+	return 0, true
+}
@@ -0,0 +1,78 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnknownWeapon = fmt.Errorf("unknown weapon, choose one of: pistol, shotgun, grenade, sniper")
+	ErrOutOfAmmo     = fmt.Errorf("out of ammo")
+)
+
+const (
+	WeaponPistol  = "pistol"
+	WeaponShotgun = "shotgun"
+	WeaponGrenade = "grenade"
+	WeaponSniper  = "sniper"
+)
+
+type WeaponSettings struct {
+	GrenadeRadius int
+	// GrenadeDelay is the time between throwing a grenade and its explosion
+	GrenadeDelay time.Duration
+	// GrenadeCount is how many grenades each player has per game, 0 means unlimited
+	GrenadeCount int
+}
+
+type weapon struct {
+	name   string
+	damage int
+	delay  time.Duration
+	// ammo is the number of shots per game, 0 means unlimited
+	ammo int
+	// hits reports whether a zombie at (zx, zy) is hit by a shot at (x, y)
+	hits func(zx, zy, x, y int) bool
+}
+
+// fuse is a thrown grenade waiting to explode, it stops while the game is paused
+type fuse struct {
+	timer      *time.Timer
+	explodesAt time.Time
+	thrower    string
+	weapon     string
+}
+
+func newWeapon(name string, settings WeaponSettings) (weapon, error) {
+	switch strings.ToLower(name) {
+	case WeaponPistol:
+		return weapon{name: WeaponPistol, damage: 1, hits: func(zx, zy, x, y int) bool {
+			return zx == x && zy == y
+		}}, nil
+	case WeaponShotgun:
+		return weapon{name: WeaponShotgun, damage: 1, hits: func(zx, zy, x, y int) bool {
+			return abs(zx-x) <= 1 && abs(zy-y) <= 1
+		}}, nil
+	case WeaponGrenade:
+		radius := settings.GrenadeRadius
+		return weapon{name: WeaponGrenade, damage: 2, delay: settings.GrenadeDelay, ammo: settings.GrenadeCount,
+			hits: func(zx, zy, x, y int) bool {
+				return (zx-x)*(zx-x)+(zy-y)*(zy-y) <= radius*radius
+			}}, nil
+	case WeaponSniper:
+		return weapon{name: WeaponSniper, damage: 1, hits: func(zx, _, x, _ int) bool {
+			return zx == x
+		}}, nil
+	default:
+		return weapon{}, ErrUnknownWeapon
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
@@ -1,30 +1,252 @@
 package game
 
-import "math/rand"
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
 
-var nameList = []string{
-	"Fuser",
-	"Leecher",
-	"Grunter",
-	"Griever",
-	"Stout_Zombie",
-	"Acher",
-	"Snacker",
-	"Skipper",
-	"Experimental_Zombie",
-	"Chewer",
+const (
+	// defaultBoardWidth is the number of columns zombies walk in, unless a game picks its own
+	defaultBoardWidth = 11
+	// wallY is the distance zombies walk before reaching the players' wall
+	wallY = 30
+)
+
+type MovementPattern string
+
+const (
+	// MovementDrift wanders sideways and forward at random
+	MovementDrift MovementPattern = "drift"
+	// MovementCharge walks straight at the wall
+	MovementCharge MovementPattern = "charge"
+	// MovementZigZag walks diagonally towards the wall, bouncing off the sides of the board
+	MovementZigZag MovementPattern = "zigzag"
+)
+
+type ZombieType struct {
+	Name      string `mapstructure:"name"`
+	HitPoints int    `mapstructure:"hitpoints"`
+	// MoveInterval is the time between steps, the game's zombie interval if zero
+	MoveInterval time.Duration   `mapstructure:"interval"`
+	Movement     MovementPattern `mapstructure:"movement"`
+	Points       int             `mapstructure:"points"`
+	// Weight is how likely the type is to be picked when spawning, relative to the other types
+	Weight int `mapstructure:"weight"`
+	// Damage is dealt to the wall when the zombie reaches it
+	Damage int `mapstructure:"damage"`
+	// SprintDistance is how close to the wall the zombie starts moving twice as fast, 0 never sprints
+	SprintDistance int `mapstructure:"sprintdistance"`
+}
+
+// ZombieTypeRegistry holds the zombie types games spawn from
+type ZombieTypeRegistry struct {
+	types       []ZombieType
+	typesByName map[string]ZombieType
+	totalWeight int
+}
+
+func NewZombieTypeRegistry(types []ZombieType) (*ZombieTypeRegistry, error) {
+	if len(types) == 0 {
+		return nil, fmt.Errorf("at least one zombie type is required")
+	}
+
+	r := &ZombieTypeRegistry{typesByName: map[string]ZombieType{}}
+
+	for _, t := range types {
+		if t.Name == "" {
+			return nil, fmt.Errorf("zombie type name is required")
+		}
+
+		if _, ok := r.typesByName[t.Name]; ok {
+			return nil, fmt.Errorf("zombie type %s is defined twice", t.Name)
+		}
+
+		switch t.Movement {
+		case "":
+			t.Movement = MovementDrift
+		case MovementDrift, MovementCharge, MovementZigZag:
+		default:
+			return nil, fmt.Errorf("zombie type %s has unsupported movement %s", t.Name, t.Movement)
+		}
+
+		if t.HitPoints <= 0 {
+			t.HitPoints = 1
+		}
+
+		if t.Damage <= 0 {
+			t.Damage = 1
+		}
+
+		if t.Points < 0 || t.MoveInterval < 0 || t.Weight < 0 || t.SprintDistance < 0 {
+			return nil, fmt.Errorf("zombie type %s has negative points, interval, weight or sprint distance", t.Name)
+		}
+
+		if t.Weight == 0 {
+			t.Weight = 1
+		}
+
+		r.types = append(r.types, t)
+		r.typesByName[t.Name] = t
+		r.totalWeight += t.Weight
+	}
+
+	return r, nil
+}
+
+// DefaultZombieTypes are used when no types are configured, their speed is relative to the base interval
+func DefaultZombieTypes(baseInterval time.Duration) []ZombieType {
+	return []ZombieType{
+		{Name: "Fuser", HitPoints: 1, Movement: MovementDrift, Points: 1},
+		{Name: "Leecher", HitPoints: 1, Movement: MovementDrift, Points: 1},
+		{Name: "Grunter", HitPoints: 1, Movement: MovementDrift, Points: 1},
+		{Name: "Griever", HitPoints: 1, Movement: MovementDrift, Points: 1, SprintDistance: 3},
+		{Name: "Stout_Zombie", HitPoints: 3, MoveInterval: baseInterval * 3 / 2, Movement: MovementCharge, Points: 3, SprintDistance: 5, Damage: 2},
+		{Name: "Acher", HitPoints: 1, Movement: MovementDrift, Points: 1},
+		{Name: "Snacker", HitPoints: 1, Movement: MovementDrift, Points: 1},
+		{Name: "Skipper", HitPoints: 1, MoveInterval: baseInterval / 2, Movement: MovementZigZag, Points: 2},
+		{Name: "Experimental_Zombie", HitPoints: 2, Movement: MovementZigZag, Points: 2},
+		{Name: "Chewer", HitPoints: 1, Movement: MovementDrift, Points: 1},
+	}
+}
+
+// scaled returns the registry with every type's own interval stretched by the ratio between a game's zombie
+// interval and the one the types were set up for, so types keep their speed relative to the other zombies
+func (r *ZombieTypeRegistry) scaled(from time.Duration, to time.Duration) *ZombieTypeRegistry {
+	if from <= 0 || to == from {
+		return r
+	}
+
+	scaled := &ZombieTypeRegistry{typesByName: map[string]ZombieType{}, totalWeight: r.totalWeight}
+	for _, t := range r.types {
+		if t.MoveInterval > 0 {
+			t.MoveInterval = time.Duration(float64(t.MoveInterval) * float64(to) / float64(from))
+		}
+
+		scaled.types = append(scaled.types, t)
+		scaled.typesByName[t.Name] = t
+	}
+
+	return scaled
+}
+
+func (r *ZombieTypeRegistry) Get(name string) (ZombieType, bool) {
+	t, ok := r.typesByName[name]
+	return t, ok
+}
+
+func (r *ZombieTypeRegistry) random(rng *rand.Rand) ZombieType {
+	pick := rng.Intn(r.totalWeight)
+
+	for _, t := range r.types {
+		if pick < t.Weight {
+			return t
+		}
+		pick -= t.Weight
+	}
+
+	return r.types[len(r.types)-1]
 }
 
 type zombie struct {
-	name string
-	x    int
-	y    int
+	name       string
+	zombieType ZombieType
+	x          int
+	y          int
+	hitPoints  int
+	// direction is the sideways direction of zigzagging zombies, -1 or 1
+	direction int
+	// lane is the username of the player the zombie walks towards in versus games
+	lane string
+
+	nextMove time.Time
+	// scheduleIndex is the zombie's position in the game's move schedule, -1 when not scheduled
+	scheduleIndex int
+	// removed is set once the zombie is killed or despawned
+	removed bool
+}
+
+func newZombie(rng *rand.Rand, zombieType ZombieType) *zombie {
+	return &zombie{
+		name:          zombieType.Name,
+		zombieType:    zombieType,
+		x:             0,
+		y:             0,
+		hitPoints:     zombieType.HitPoints,
+		direction:     1,
+		scheduleIndex: -1,
+	}
+}
+
+func (z *zombie) moveInterval(defaultInterval time.Duration) time.Duration {
+	interval := defaultInterval
+	if z.zombieType.MoveInterval > 0 {
+		interval = z.zombieType.MoveInterval
+	}
+
+	if z.zombieType.SprintDistance > 0 && wallY-z.y <= z.zombieType.SprintDistance {
+		interval /= 2
+	}
+
+	return interval
+}
+
+// firstMoveDelay spreads the first moves over a whole interval, so zombies
+// spawned together don't walk in lockstep
+func (z *zombie) firstMoveDelay(rng *rand.Rand, defaultInterval time.Duration) time.Duration {
+	interval := z.moveInterval(defaultInterval)
+	if interval <= 0 {
+		return 0
+	}
+
+	return interval/2 + time.Duration(rng.Int63n(int64(interval/2)+1))
 }
 
-func newZombie() zombie {
-	return zombie{
-		name: nameList[rand.Intn(len(nameList))],
-		x:    0,
-		y:    0,
+func (z *zombie) move(rng *rand.Rand, boardWidth int) {
+	boardMaxX := boardWidth - 1
+
+	switch z.zombieType.Movement {
+	case MovementCharge:
+		z.y++
+	case MovementZigZag:
+		// A board one square wide leaves no room to step sideways, so the zombie walks straight
+		if boardMaxX > 0 {
+			if z.x+z.direction < 0 || z.x+z.direction > boardMaxX {
+				z.direction = -z.direction
+			}
+			z.x += z.direction
+		}
+		z.y++
+	default:
+		switch step := rng.Intn(4); {
+		case boardMaxX == 0 || step > 1:
+			z.y++
+		case step == 0:
+			if z.x > 0 {
+				z.x--
+			} else {
+				z.x++
+			}
+		default:
+			if z.x < boardMaxX {
+				z.x++
+			} else {
+				z.x--
+			}
+		}
+	}
+
+	if z.y > wallY {
+		z.y = wallY
 	}
 }
+
+func (z *zombie) reachedWall() bool {
+	return z.y >= wallY
+}
+
+// hit deals damage and reports whether the zombie died
+func (z *zombie) hit(damage int) bool {
+	z.hitPoints -= damage
+	return z.hitPoints <= 0
+}
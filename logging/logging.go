@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	FieldConnectionID = "connection_id"
+	FieldConnectionIP = "connection_ip"
+	FieldAPI          = "api"
+	FieldGameID       = "game_id"
+	FieldUsername     = "username"
+	FieldNodeID       = "node_id"
+)
+
+type fieldsKey struct{}
+
+type Settings struct {
+	Level  string
+	Format string
+}
+
+// Setup configures the global logger, every entry logged with a context gets the
+// fields stored in that context by WithFields
+func Setup(settings Settings) error {
+	level, err := logrus.ParseLevel(settings.Level)
+	if err != nil {
+		return err
+	}
+	logrus.SetLevel(level)
+
+	switch settings.Format {
+	case "", "text":
+		logrus.SetFormatter(&logrus.TextFormatter{})
+	case "json":
+		logrus.SetFormatter(&logrus.JSONFormatter{})
+	default:
+		return fmt.Errorf("log format %s is not supported", settings.Format)
+	}
+
+	logrus.AddHook(contextHook{})
+
+	return nil
+}
+
+// WithFields returns a copy of ctx carrying the given log fields on top of the ones already in it
+func WithFields(ctx context.Context, fields logrus.Fields) context.Context {
+	merged := logrus.Fields{}
+	for k, v := range Fields(ctx) {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+func WithField(ctx context.Context, key string, value interface{}) context.Context {
+	return WithFields(ctx, logrus.Fields{key: value})
+}
+
+func Fields(ctx context.Context) logrus.Fields {
+	if ctx == nil {
+		return nil
+	}
+
+	fields, _ := ctx.Value(fieldsKey{}).(logrus.Fields)
+	return fields
+}
+
+type contextHook struct{}
+
+func (contextHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (contextHook) Fire(entry *logrus.Entry) error {
+	for k, v := range Fields(entry.Context) {
+		// fields set explicitly on the entry take precedence
+		if _, ok := entry.Data[k]; !ok {
+			entry.Data[k] = v
+		}
+	}
+
+	return nil
+}
@@ -21,6 +21,11 @@ func main() {
 	quit := make(chan os.Signal, 1)
 	listenForExit(quit)
 
+	if err = service.Stop(); err != nil {
+		logrus.Errorf("error stopping service: %s", err.Error())
+		os.Exit(1)
+	}
+
 	os.Exit(0)
 }
 
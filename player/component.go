@@ -2,36 +2,84 @@ package player
 
 import (
 	"fmt"
+	"strings"
+	"sync"
+
 	"github.com/google/uuid"
 
 	cmap "github.com/orcaman/concurrent-map/v2"
 )
 
+const maxUsernameSuffix = 100
+
 var (
 	ErrPlayerNotFound = fmt.Errorf("player not found")
 )
 
 type Component interface {
-	NewPlayer(player Player) (string, error)
+	NewPlayer(player Player) (Player, error)
 	GetPlayerByConnectionID(connectionID string) (Player, error)
 	GetPlayersByGameID(gameID string) ([]Player, error)
 	DeletePlayerByConnectionID(connectionID string)
 }
 
 type component struct {
+	settings       Settings
+	usernameFilter UsernameFilter
+
+	// newPlayerMu makes the username uniqueness check and insert atomic
+	newPlayerMu sync.Mutex
 	playerStore cmap.ConcurrentMap[string, Player]
 }
 
-func NewPlayerComponent() *component {
-	return &component{playerStore: cmap.New[Player]()}
+func NewPlayerComponent(settings Settings, usernameFilter UsernameFilter) (*component, error) {
+	if err := settings.validate(); err != nil {
+		return nil, err
+	}
+
+	return &component{
+		settings:       settings,
+		usernameFilter: usernameFilter,
+		playerStore:    cmap.New[Player](),
+	}, nil
 }
 
-func (c *component) NewPlayer(player Player) (string, error) {
-	userID := uuid.NewString()
+// NewPlayer validates the username and stores the player, the returned player
+// might have a suffixed username if duplicates are renamed
+func (c *component) NewPlayer(player Player) (Player, error) {
+	if err := validateUsername(c.settings, c.usernameFilter, player.Username); err != nil {
+		return Player{}, err
+	}
+
+	c.newPlayerMu.Lock()
+	defer c.newPlayerMu.Unlock()
 
-	c.playerStore.Set(userID, player)
+	username := player.Username
+	for suffix := 2; c.usernameTaken(player.GameID, username); suffix++ {
+		if !c.settings.SuffixDuplicateUsernames || suffix > maxUsernameSuffix {
+			return Player{}, ErrUsernameTaken
+		}
+
+		username = suffixUsername(player.Username, suffix, c.settings.MaxUsernameLength)
+	}
+	player.Username = username
+
+	c.playerStore.Set(uuid.NewString(), player)
+
+	return player, nil
+}
+
+// usernameTaken ignores case, so nobody can pass as another player by changing a letter's case
+func (c *component) usernameTaken(gameID string, username string) bool {
+	taken := false
+
+	c.playerStore.IterCb(func(_ string, p Player) {
+		if p.GameID == gameID && strings.EqualFold(p.Username, username) {
+			taken = true
+		}
+	})
 
-	return userID, nil
+	return taken
 }
 
 func (c *component) GetPlayerByConnectionID(connectionID string) (Player, error) {
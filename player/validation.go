@@ -0,0 +1,159 @@
+package player
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	ErrInvalidUsername = fmt.Errorf("invalid username")
+	ErrUsernameTaken   = fmt.Errorf("username is already taken in this game")
+)
+
+type Settings struct {
+	MinUsernameLength int
+	MaxUsernameLength int
+	// SuffixDuplicateUsernames renames players joining with a taken name (alice -> alice_2)
+	// instead of refusing them
+	SuffixDuplicateUsernames bool
+}
+
+// validate refuses length bounds no username can meet, or too short for the longest suffix
+func (s Settings) validate() error {
+	if s.MinUsernameLength < 1 || s.MaxUsernameLength < s.MinUsernameLength {
+		return fmt.Errorf("username lengths must be at least 1 and the maximum at least the minimum, got %d to %d",
+			s.MinUsernameLength, s.MaxUsernameLength)
+	}
+
+	if longestSuffix := len(usernameSuffix(maxUsernameSuffix)); s.SuffixDuplicateUsernames && s.MaxUsernameLength <= longestSuffix {
+		return fmt.Errorf("suffixing duplicate usernames requires a maximum username length above %d, got %d",
+			longestSuffix, s.MaxUsernameLength)
+	}
+
+	return nil
+}
+
+// UsernameFilter decides whether a username is acceptable, f.x. not abusive
+type UsernameFilter interface {
+	Allowed(username string) bool
+}
+
+func validateUsername(settings Settings, filter UsernameFilter, username string) error {
+	length := utf8.RuneCountInString(username)
+	if length < settings.MinUsernameLength || length > settings.MaxUsernameLength {
+		return fmt.Errorf("%w: must be between %d and %d characters long",
+			ErrInvalidUsername, settings.MinUsernameLength, settings.MaxUsernameLength)
+	}
+
+	for _, r := range username {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("_-.", r) {
+			return fmt.Errorf("%w: may only contain letters, digits, '_', '-' and '.'", ErrInvalidUsername)
+		}
+	}
+
+	if filter != nil && !filter.Allowed(username) {
+		return fmt.Errorf("%w: username is not allowed", ErrInvalidUsername)
+	}
+
+	return nil
+}
+
+// suffixUsername appends the suffix, trimming the username so the result stays within maxLength
+func suffixUsername(username string, suffix int, maxLength int) string {
+	suffixString := usernameSuffix(suffix)
+
+	runes := []rune(username)
+	if maxLength > 0 && len(runes)+len(suffixString) > maxLength {
+		keep := maxLength - len(suffixString)
+		if keep < 0 {
+			keep = 0
+		}
+
+		runes = runes[:keep]
+	}
+
+	return string(runes) + suffixString
+}
+
+func usernameSuffix(suffix int) string {
+	return fmt.Sprintf("_%d", suffix)
+}
+
+// denyListFilter refuses usernames containing any of the denied words, the
+// comparison ignores case, separators and common character substitutions
+type denyListFilter struct {
+	words []string
+}
+
+var _ UsernameFilter = (*denyListFilter)(nil)
+
+// NewDenyListFilterFromFile reads one denied word per line, empty lines and lines starting with # are skipped
+func NewDenyListFilterFromFile(path string) (*denyListFilter, error) {
+	entries, err := ReadDenyList(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var words []string
+	for _, entry := range entries {
+		if word := NormalizeForFilter(entry); word != "" {
+			words = append(words, word)
+		}
+	}
+
+	return &denyListFilter{words: words}, nil
+}
+
+// ReadDenyList reads the entries of a deny list file, one per line, empty lines and lines starting with # are skipped.
+// The game's chat filter shares the file with the username filter.
+func ReadDenyList(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []string
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		entries = append(entries, line)
+	}
+
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func (f *denyListFilter) Allowed(text string) bool {
+	normalized := NormalizeForFilter(text)
+
+	for _, word := range f.words {
+		if strings.Contains(normalized, word) {
+			return false
+		}
+	}
+
+	return true
+}
+
+var filterReplacer = strings.NewReplacer(
+	"0", "o", "1", "i", "3", "e", "4", "a", "5", "s", "7", "t", "@", "a", "$", "s",
+	"_", "", "-", "", ".", "", " ", "",
+)
+
+// NormalizeForFilter lowercases the text and undoes common character substitutions and separators
+func NormalizeForFilter(text string) string {
+	return filterReplacer.Replace(strings.ToLower(text))
+}
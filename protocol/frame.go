@@ -0,0 +1,386 @@
+// Package protocol implements the compact binary protocol, see SPEC.md for the wire format.
+// Clients negotiate it by asking for the Subprotocol when opening the websocket.
+package protocol
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Subprotocol is the websocket subprotocol a client asks for to talk binary frames
+const Subprotocol = "ttz.binary.v1"
+
+var (
+	ErrEmptyFrame       = fmt.Errorf("frame is empty")
+	ErrUnknownFrameType = fmt.Errorf("unknown frame type")
+	ErrTruncatedFrame   = fmt.Errorf("frame is truncated")
+	ErrTrailingBytes    = fmt.Errorf("frame has trailing bytes")
+)
+
+type FrameType byte
+
+// Frames sent by clients
+const (
+	FrameStart   FrameType = 0x01
+	FrameJoin    FrameType = 0x02
+	FrameShoot   FrameType = 0x03
+	FrameCommand FrameType = 0x0F
+)
+
+// Frames sent by the server
+const (
+	FrameGame     FrameType = 0x81
+	FrameName     FrameType = 0x82
+	FrameWalk     FrameType = 0x83
+	FrameBoom     FrameType = 0x84
+	FrameGameOver FrameType = 0x85
+	FrameText     FrameType = 0x8F
+)
+
+type Frame interface {
+	Type() FrameType
+	appendPayload(b []byte) []byte
+}
+
+// Start starts a new game, like START {username} {rules...}
+type Start struct {
+	Username string
+	Rules    []string
+}
+
+// Join joins a running game, like JOIN {gameID} {username}
+type Join struct {
+	GameID   string
+	Username string
+}
+
+// Shoot shoots at a square, like SHOOT {x} {y}
+type Shoot struct {
+	X, Y int
+}
+
+// Command carries any text command without a frame of its own, f.x. WEAPON shotgun
+type Command struct {
+	Text string
+}
+
+// Game answers Start with the ID of the new game, like GAME {gameID} {rules...}
+type Game struct {
+	GameID string
+	Rules  []string
+}
+
+// Name introduces a zombie, later frames only refer to it by ID
+type Name struct {
+	ID   uint64
+	Name string
+}
+
+// Walk holds the new zombie positions of a tick, like DELTA
+type Walk struct {
+	Seq     uint64
+	Zombies []Position
+	// Gone are the IDs of zombies taken off the board without a kill, f.x. despawning at the wall
+	Gone []uint64
+}
+
+type Position struct {
+	ID   uint64
+	X, Y int
+	// Approximate is set for blips, zombies only known to be somewhere near
+	Approximate bool
+	// Lane is the username of the player whose lane the zombie walks in, empty outside of versus games
+	Lane string
+}
+
+// Boom is the result of a shot, like BOOM {username} {points} {hits...}
+type Boom struct {
+	Username string
+	Points   int
+	Hits     []Hit
+}
+
+// Hit is a zombie hit by a shot, it is killed if it has no HitPoints left
+type Hit struct {
+	ID        uint64
+	HitPoints int
+}
+
+type GameOverResult byte
+
+const (
+	ResultWin GameOverResult = iota
+	ResultLose
+	ResultWinner
+	ResultSurvived
+	ResultEnded
+)
+
+// GameOver ends the game, like GAMEOVER {result} {details...}
+type GameOver struct {
+	Result  GameOverResult
+	Details []string
+}
+
+// Text carries any message without a frame of its own
+type Text struct {
+	Text string
+}
+
+func (Start) Type() FrameType    { return FrameStart }
+func (Join) Type() FrameType     { return FrameJoin }
+func (Shoot) Type() FrameType    { return FrameShoot }
+func (Command) Type() FrameType  { return FrameCommand }
+func (Game) Type() FrameType     { return FrameGame }
+func (Name) Type() FrameType     { return FrameName }
+func (Walk) Type() FrameType     { return FrameWalk }
+func (Boom) Type() FrameType     { return FrameBoom }
+func (GameOver) Type() FrameType { return FrameGameOver }
+func (Text) Type() FrameType     { return FrameText }
+
+// Marshal encodes the frame as a type byte followed by its payload
+func Marshal(f Frame) []byte {
+	return f.appendPayload([]byte{byte(f.Type())})
+}
+
+// Unmarshal decodes a frame, the caller switches on its type
+func Unmarshal(data []byte) (Frame, error) {
+	if len(data) == 0 {
+		return nil, ErrEmptyFrame
+	}
+
+	r := &reader{data: data[1:]}
+
+	var f Frame
+	switch FrameType(data[0]) {
+	case FrameStart:
+		f = Start{Username: r.string(), Rules: r.strings()}
+	case FrameJoin:
+		f = Join{GameID: r.string(), Username: r.string()}
+	case FrameShoot:
+		f = Shoot{X: r.int(), Y: r.int()}
+	case FrameCommand:
+		f = Command{Text: r.string()}
+	case FrameGame:
+		f = Game{GameID: r.string(), Rules: r.strings()}
+	case FrameName:
+		f = Name{ID: r.uint(), Name: r.string()}
+	case FrameWalk:
+		walk := Walk{Seq: r.uint()}
+		for n := r.count(); n > 0; n-- {
+			p := Position{ID: r.uint(), X: r.int(), Y: r.int()}
+
+			flags := r.byte()
+			p.Approximate = flags&flagApproximate != 0
+			if flags&flagLane != 0 {
+				p.Lane = r.string()
+			}
+
+			walk.Zombies = append(walk.Zombies, p)
+		}
+		for n := r.count(); n > 0; n-- {
+			walk.Gone = append(walk.Gone, r.uint())
+		}
+		f = walk
+	case FrameBoom:
+		boom := Boom{Username: r.string(), Points: r.int()}
+		for n := r.count(); n > 0; n-- {
+			boom.Hits = append(boom.Hits, Hit{ID: r.uint(), HitPoints: r.int()})
+		}
+		f = boom
+	case FrameGameOver:
+		f = GameOver{Result: GameOverResult(r.byte()), Details: r.strings()}
+	case FrameText:
+		f = Text{Text: r.string()}
+	default:
+		return nil, fmt.Errorf("%w 0x%02x", ErrUnknownFrameType, data[0])
+	}
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	if len(r.data) > 0 {
+		return nil, ErrTrailingBytes
+	}
+
+	return f, nil
+}
+
+const (
+	flagApproximate = 0x01
+	flagLane        = 0x02
+)
+
+func (f Start) appendPayload(b []byte) []byte {
+	return appendStrings(appendString(b, f.Username), f.Rules)
+}
+
+func (f Join) appendPayload(b []byte) []byte {
+	return appendString(appendString(b, f.GameID), f.Username)
+}
+
+func (f Shoot) appendPayload(b []byte) []byte {
+	return appendInt(appendInt(b, f.X), f.Y)
+}
+
+func (f Command) appendPayload(b []byte) []byte {
+	return appendString(b, f.Text)
+}
+
+func (f Game) appendPayload(b []byte) []byte {
+	return appendStrings(appendString(b, f.GameID), f.Rules)
+}
+
+func (f Name) appendPayload(b []byte) []byte {
+	return appendString(binary.AppendUvarint(b, f.ID), f.Name)
+}
+
+func (f Walk) appendPayload(b []byte) []byte {
+	b = binary.AppendUvarint(b, f.Seq)
+	b = binary.AppendUvarint(b, uint64(len(f.Zombies)))
+	for _, p := range f.Zombies {
+		var flags byte
+		if p.Approximate {
+			flags |= flagApproximate
+		}
+		if p.Lane != "" {
+			flags |= flagLane
+		}
+
+		b = appendInt(appendInt(binary.AppendUvarint(b, p.ID), p.X), p.Y)
+		b = append(b, flags)
+		if p.Lane != "" {
+			b = appendString(b, p.Lane)
+		}
+	}
+
+	b = binary.AppendUvarint(b, uint64(len(f.Gone)))
+	for _, id := range f.Gone {
+		b = binary.AppendUvarint(b, id)
+	}
+
+	return b
+}
+
+func (f Boom) appendPayload(b []byte) []byte {
+	b = appendInt(appendString(b, f.Username), f.Points)
+	b = binary.AppendUvarint(b, uint64(len(f.Hits)))
+	for _, h := range f.Hits {
+		b = appendInt(binary.AppendUvarint(b, h.ID), h.HitPoints)
+	}
+
+	return b
+}
+
+func (f GameOver) appendPayload(b []byte) []byte {
+	return appendStrings(append(b, byte(f.Result)), f.Details)
+}
+
+func (f Text) appendPayload(b []byte) []byte {
+	return appendString(b, f.Text)
+}
+
+// appendInt writes a number which is never negative, f.x. a coordinate, as an unsigned varint
+func appendInt(b []byte, n int) []byte {
+	if n < 0 {
+		n = 0
+	}
+
+	return binary.AppendUvarint(b, uint64(n))
+}
+
+func appendString(b []byte, s string) []byte {
+	return append(binary.AppendUvarint(b, uint64(len(s))), s...)
+}
+
+func appendStrings(b []byte, s []string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	for _, item := range s {
+		b = appendString(b, item)
+	}
+
+	return b
+}
+
+// reader decodes a payload, the first error sticks and every later read returns zero values
+type reader struct {
+	data []byte
+	err  error
+}
+
+func (r *reader) uint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+
+	n, size := binary.Uvarint(r.data)
+	if size <= 0 {
+		r.err = ErrTruncatedFrame
+		return 0
+	}
+
+	r.data = r.data[size:]
+
+	return n
+}
+
+func (r *reader) int() int {
+	n := r.uint()
+	if n > uint64(maxInt) {
+		r.err = ErrTruncatedFrame
+		return 0
+	}
+
+	return int(n)
+}
+
+// count reads the length of a list, which can't be longer than the bytes left
+func (r *reader) count() int {
+	n := r.int()
+	if n > len(r.data) {
+		r.err = ErrTruncatedFrame
+		return 0
+	}
+
+	return n
+}
+
+func (r *reader) byte() byte {
+	if r.err != nil {
+		return 0
+	}
+
+	if len(r.data) == 0 {
+		r.err = ErrTruncatedFrame
+		return 0
+	}
+
+	b := r.data[0]
+	r.data = r.data[1:]
+
+	return b
+}
+
+func (r *reader) string() string {
+	n := r.count()
+	if r.err != nil {
+		return ""
+	}
+
+	s := string(r.data[:n])
+	r.data = r.data[n:]
+
+	return s
+}
+
+func (r *reader) strings() []string {
+	var s []string
+	for n := r.count(); n > 0 && r.err == nil; n-- {
+		s = append(s, r.string())
+	}
+
+	return s
+}
+
+const maxInt = int(^uint(0) >> 1)
@@ -0,0 +1,75 @@
+package protocol_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ScruffyPants/talk-to-zombies/protocol"
+)
+
+func TestBinaryFrameRoundTrip(t *testing.T) {
+	for _, frame := range []protocol.Frame{
+		protocol.Start{Username: "alice", Rules: []string{"mode=coop", "zombies=3"}},
+		protocol.Join{GameID: "game", Username: "bob"},
+		protocol.Shoot{X: 3, Y: 300},
+		protocol.Command{Text: "WEAPON shotgun"},
+		protocol.Game{GameID: "game"},
+		protocol.Name{ID: 1, Name: "Tank"},
+		protocol.Walk{Seq: 7, Zombies: []protocol.Position{{ID: 1, X: 3, Y: 12}, {ID: 200, X: 0, Y: 29, Approximate: true}}},
+		protocol.Walk{Seq: 8, Zombies: []protocol.Position{{ID: 1, X: 3, Y: 13, Lane: "alice"}, {ID: 2, X: 0, Y: 29, Approximate: true, Lane: "bob"}}},
+		protocol.Walk{Seq: 9, Gone: []uint64{1, 300}},
+		protocol.Boom{Username: "alice", Points: 1, Hits: []protocol.Hit{{ID: 1}, {ID: 2, HitPoints: 2}}},
+		protocol.GameOver{Result: protocol.ResultSurvived, Details: []string{"3", "95"}},
+		protocol.Text{Text: "MUTED bob"},
+	} {
+		decoded, err := protocol.Unmarshal(protocol.Marshal(frame))
+		require.NoError(t, err)
+		assert.Equal(t, frame, decoded)
+	}
+
+	assert.Equal(t, []byte{0x83, 0x07, 0x01, 0x01, 0x03, 0x0c, 0x00, 0x00},
+		protocol.Marshal(protocol.Walk{Seq: 7, Zombies: []protocol.Position{{ID: 1, X: 3, Y: 12}}}))
+}
+
+func TestBinaryFrameErrors(t *testing.T) {
+	_, err := protocol.Unmarshal(nil)
+	assert.ErrorIs(t, err, protocol.ErrEmptyFrame)
+
+	_, err = protocol.Unmarshal([]byte{0x7f})
+	assert.ErrorIs(t, err, protocol.ErrUnknownFrameType)
+
+	// A username claiming more bytes than the frame holds
+	_, err = protocol.Unmarshal([]byte{byte(protocol.FrameStart), 0x05, 'a'})
+	assert.ErrorIs(t, err, protocol.ErrTruncatedFrame)
+
+	_, err = protocol.Unmarshal(append(protocol.Marshal(protocol.Shoot{X: 1, Y: 2}), 0x00))
+	assert.ErrorIs(t, err, protocol.ErrTrailingBytes)
+}
+
+func TestTextEncoderSendsNamesOnce(t *testing.T) {
+	encoder := protocol.NewTextEncoder()
+
+	assert.Equal(t, []protocol.Frame{
+		protocol.Name{ID: 1, Name: "Tank"},
+		protocol.Name{ID: 2, Name: "Tank_2"},
+		protocol.Walk{Seq: 1, Zombies: []protocol.Position{{ID: 1, X: 0, Y: 1}, {ID: 2, X: 4, Y: 2, Approximate: true}}},
+	}, encoder.Encode("DELTA seq=1 zombies=Tank:0:1 blips=Tank_2:4:2 gone="))
+
+	assert.Equal(t, []protocol.Frame{
+		protocol.Walk{Seq: 2, Zombies: []protocol.Position{{ID: 1, X: 0, Y: 2, Lane: "alice"}}},
+	}, encoder.Encode("DELTA seq=2 zombies=Tank:0:2:alice blips= gone="))
+
+	assert.Equal(t, []protocol.Frame{
+		protocol.Walk{Seq: 3, Gone: []uint64{1}},
+	}, encoder.Encode("DELTA seq=3 zombies= blips= gone=Tank"))
+
+	assert.Equal(t, []protocol.Frame{
+		protocol.Boom{Username: "alice", Points: 1, Hits: []protocol.Hit{{ID: 1}, {ID: 2, HitPoints: 1}}},
+	}, encoder.Encode("BOOM alice 1 Tank Tank_2:1"))
+
+	assert.Equal(t, []protocol.Frame{protocol.Boom{Username: "alice", Points: 0}}, encoder.Encode("BOOM alice 0"))
+	assert.Equal(t, []protocol.Frame{protocol.GameOver{Result: protocol.ResultWinner, Details: []string{"bob"}}}, encoder.Encode("GAMEOVER WINNER bob"))
+	assert.Equal(t, []protocol.Frame{protocol.Text{Text: "ROSTER alice bob"}}, encoder.Encode("ROSTER alice bob"))
+}
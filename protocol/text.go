@@ -0,0 +1,166 @@
+package protocol
+
+import (
+	"strconv"
+	"strings"
+)
+
+var gameOverResults = map[string]GameOverResult{
+	"WIN":      ResultWin,
+	"LOSE":     ResultLose,
+	"WINNER":   ResultWinner,
+	"SURVIVED": ResultSurvived,
+	"ENDED":    ResultEnded,
+}
+
+// textEncoder turns the server's text messages into frames for a single connection,
+// it remembers which zombie names the client already knows and sends a Name frame
+// before the first frame referring to a new one
+type textEncoder struct {
+	names map[string]uint64
+}
+
+func NewTextEncoder() *textEncoder {
+	return &textEncoder{names: map[string]uint64{}}
+}
+
+// Encode returns the frames for a text message, messages without a frame of their own
+// or which can't be parsed are sent as Text
+func (e *textEncoder) Encode(message string) []Frame {
+	var introduced []Frame
+
+	id := func(name string) uint64 {
+		if id, ok := e.names[name]; ok {
+			return id
+		}
+
+		id := uint64(len(e.names) + 1)
+		e.names[name] = id
+		introduced = append(introduced, Name{ID: id, Name: name})
+
+		return id
+	}
+
+	frame, ok := parseText(message, id)
+	if !ok {
+		// Names introduced while parsing are still sent so the table stays in step with the client
+		return append(introduced, Text{Text: message})
+	}
+
+	return append(introduced, frame)
+}
+
+func parseText(message string, id func(name string) uint64) (Frame, bool) {
+	fields := strings.Split(message, " ")
+
+	switch fields[0] {
+	case "GAME":
+		if len(fields) < 2 {
+			return nil, false
+		}
+
+		return Game{GameID: fields[1], Rules: fields[2:]}, true
+	case "DELTA":
+		return parseDelta(fields[1:], id)
+	case "BOOM":
+		return parseBoom(fields[1:], id)
+	case "GAMEOVER":
+		if len(fields) < 2 {
+			return nil, false
+		}
+
+		result, ok := gameOverResults[fields[1]]
+		if !ok {
+			return nil, false
+		}
+
+		return GameOver{Result: result, Details: fields[2:]}, true
+	default:
+		return nil, false
+	}
+}
+
+// parseDelta reads DELTA seq={n} zombies={name}:{x}:{y}[:{lane}],... blips={name}:{x}:{y}[:{lane}],... gone={name},...
+func parseDelta(fields []string, id func(name string) uint64) (Frame, bool) {
+	walk := Walk{}
+
+	for _, field := range fields {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return nil, false
+		}
+
+		switch key {
+		case "seq":
+			seq, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				return nil, false
+			}
+			walk.Seq = seq
+		case "zombies", "blips":
+			if value == "" {
+				continue
+			}
+
+			for _, position := range strings.Split(value, ",") {
+				parts := strings.Split(position, ":")
+				if len(parts) != 3 && len(parts) != 4 {
+					return nil, false
+				}
+
+				x, errX := strconv.Atoi(parts[1])
+				y, errY := strconv.Atoi(parts[2])
+				if errX != nil || errY != nil {
+					return nil, false
+				}
+
+				p := Position{ID: id(parts[0]), X: x, Y: y, Approximate: key == "blips"}
+				if len(parts) == 4 {
+					p.Lane = parts[3]
+				}
+
+				walk.Zombies = append(walk.Zombies, p)
+			}
+		case "gone":
+			if value == "" {
+				continue
+			}
+
+			for _, name := range strings.Split(value, ",") {
+				walk.Gone = append(walk.Gone, id(name))
+			}
+		}
+	}
+
+	return walk, true
+}
+
+// parseBoom reads BOOM {username} {points} {name}|{name}:{hit points}...
+func parseBoom(fields []string, id func(name string) uint64) (Frame, bool) {
+	if len(fields) < 2 {
+		return nil, false
+	}
+
+	points, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, false
+	}
+
+	boom := Boom{Username: fields[0], Points: points}
+
+	for _, hit := range fields[2:] {
+		name, hitPoints, wounded := strings.Cut(hit, ":")
+
+		h := Hit{}
+		if wounded {
+			if h.HitPoints, err = strconv.Atoi(hitPoints); err != nil {
+				return nil, false
+			}
+		}
+
+		h.ID = id(name)
+		boom.Hits = append(boom.Hits, h)
+	}
+
+	return boom, true
+}